@@ -0,0 +1,3475 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package alerta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/awnumar/memguard"
+	"github.com/coreos/go-semver/semver"
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/http2"
+	netproxy "golang.org/x/net/proxy"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	tlsint "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const (
+	defaultStatusPath   = "/management/status"
+	defaultMetricsPath  = "/management/metrics"
+	defaultCountsPath   = "/alerts/count"
+	defaultHeartbeats   = "/heartbeats"
+	defaultEnvironments = "/environments"
+	defaultBlackouts    = "/blackouts"
+	defaultGroupByPath  = "/alerts"
+	defaultHealthcheck  = "/management/healthcheck"
+	defaultMaxBodySize  = 32 * 1024 * 1024
+	defaultRawSize      = 4096
+	defaultUserAgent    = "Telegraf (alerta)"
+	defaultAccept       = "application/json"
+	defaultCustomerPath = "customer"
+
+	// Maximum number of bytes read from the remainder of a response body to
+	// reuse the connection
+	maxDrainSize = 64 * 1024
+	// Maximum of max_retries, the waits are capped at the response timeout
+	// anyway so more retries would only block the gather
+	maxRetriesLimit = 30
+)
+
+// errBodyTooLarge is returned when a response exceeds max_body_size
+var errBodyTooLarge = errors.New("response exceeded max_body_size")
+
+// errNotModified is returned for conditional requests answered with 304
+var errNotModified = errors.New("not modified")
+
+// releaseSecret wipes a secret after use, replaceable for testing
+var releaseSecret = config.ReleaseSecret
+
+// timeNow returns the current time, replaceable for testing
+var timeNow = time.Now
+
+type Alerta struct {
+	Urls                []string                  `toml:"urls"`
+	URLTemplate         string                    `toml:"url_template"`
+	URLTemplateVars     []map[string]string       `toml:"url_template_vars"`
+	Path                string                    `toml:"path"`
+	HTTPMethod          string                    `toml:"http_method"`
+	HTTPBody            string                    `toml:"http_body"`
+	Format              string                    `toml:"format"`
+	AutoDetectPath      bool                      `toml:"auto_detect_path"`
+	Groups              []string                  `toml:"groups"`
+	MetricNameInclude   []string                  `toml:"metric_name_include"`
+	MetricNameExclude   []string                  `toml:"metric_name_exclude"`
+	PreferCount         []string                  `toml:"prefer_count"`
+	PreferValue         []string                  `toml:"prefer_value"`
+	TagMetrics          bool                      `toml:"tag_metrics"`
+	SplitMeasurements   bool                      `toml:"split_measurements"`
+	RequireMetrics      bool                      `toml:"require_metrics"`
+	WarnOnNoMetrics     bool                      `toml:"warn_on_no_metrics"`
+	MetricsJSONKey      string                    `toml:"metrics_json_key"`
+	DedupeURLs          bool                      `toml:"dedupe_urls"`
+	FailFast            bool                      `toml:"fail_fast"`
+	ConditionalRequests bool                      `toml:"conditional_requests"`
+	ReportDeltas        bool                      `toml:"report_deltas"`
+	ComputeTotals       bool                      `toml:"compute_totals"`
+	URLTags             []URLTags                 `toml:"url_tags"`
+	URLAuth             []URLAuth                 `toml:"url_auth"`
+	FieldPaths          []FieldPath               `toml:"field_paths"`
+	CustomerPath        string                    `toml:"customer_path"`
+	URLTag              string                    `toml:"url_tag"`
+	ExcludeURLTag       bool                      `toml:"exclude_url_tag"`
+	Measurement         string                    `toml:"measurement"`
+	FieldPrefix         string                    `toml:"field_prefix"`
+	UptimeUnit          string                    `toml:"uptime_unit"`
+	ResponseTimeout     config.Duration           `toml:"response_timeout"`
+	Headers             map[string]*config.Secret `toml:"headers"`
+	UserAgent           string                    `toml:"user_agent"`
+	FollowRedirects     bool                      `toml:"follow_redirects"`
+	Accept              string                    `toml:"accept"`
+	MaxRetries          int                       `toml:"max_retries"`
+	RetryBackoff        config.Duration           `toml:"retry_backoff"`
+
+	// Additional endpoints relative to the API root
+	AlertCounts      bool   `toml:"alert_counts"`
+	AlertCountsPath  string `toml:"alert_counts_path"`
+	Heartbeats       bool   `toml:"heartbeats"`
+	HeartbeatsPath   string `toml:"heartbeats_path"`
+	Environments     bool   `toml:"environments"`
+	EnvironmentsPath string `toml:"environments_path"`
+	Blackouts        bool   `toml:"blackouts"`
+	BlackoutsPath    string `toml:"blackouts_path"`
+	GroupBy          string `toml:"group_by"`
+	GroupByPath      string `toml:"group_by_path"`
+	TopN             int    `toml:"top_n"`
+	Healthcheck      bool   `toml:"healthcheck"`
+	HealthcheckPath  string `toml:"healthcheck_path"`
+	GatherDuration   bool   `toml:"gather_duration"`
+	RequestCounts    bool   `toml:"request_counts"`
+
+	// Maximum number of URLs gathered in parallel, zero means unlimited
+	MaxConcurrentRequests int `toml:"max_concurrent_requests"`
+	// Maximum random delay of the requests to each URL within a gather
+	URLJitter config.Duration `toml:"url_jitter"`
+
+	// Maximum number of bytes read from the wire, zero means unlimited
+	ResponseBodyLimit int64 `toml:"response_body_limit"`
+	// Maximum size of the decompressed response
+	MaxBodySize config.Size `toml:"max_body_size"`
+	// Try to parse the response as JSON regardless of its Content-Type
+	InsecureParseAnyContentType bool `toml:"insecure_parse_any_content_type"`
+	// Attach the status document to the metrics for debugging
+	DebugIncludeRaw      bool        `toml:"debug_include_raw"`
+	DebugRawResponseSize config.Size `toml:"debug_raw_response_size"`
+	// Break down the response time of the status request into its phases
+	TraceTimings bool `toml:"trace_timings"`
+
+	// Transport settings
+	HTTPProxyURL string `toml:"http_proxy_url"`
+	// Headers of the CONNECT requests to the proxy, e.g. for custom schemes
+	// of proxy authentication
+	ProxyHeaders        map[string]*config.Secret `toml:"proxy_headers"`
+	MaxIdleConns        int                       `toml:"max_idle_conns"`
+	MaxIdleConnsPerHost int                       `toml:"max_idle_conns_per_host"`
+	IdleConnTimeout     config.Duration           `toml:"idle_conn_timeout"`
+	DisableKeepAlives   bool                      `toml:"disable_keep_alives"`
+	HTTP2               string                    `toml:"http2"`
+	KeepAlive           config.Duration           `toml:"keep_alive"`
+	DialTimeout         config.Duration           `toml:"dial_timeout"`
+	TLSHandshakeTimeout config.Duration           `toml:"tls_handshake_timeout"`
+	// Cache the addresses of the hosts for this long instead of resolving
+	// them for every new connection
+	DNSCacheTTL config.Duration `toml:"dns_cache_ttl"`
+	// Resolve the hosts via this nameserver instead of the system resolver
+	DNSNameserver string `toml:"dns_nameserver"`
+
+	// HTTP Basic Auth Credentials
+	Username config.Secret `toml:"username"`
+	Password config.Secret `toml:"password"`
+	// File to look up the basic auth credentials by host if none are set
+	NetrcPath string `toml:"netrc_path"`
+	// Send the credentials with every request instead of only after a
+	// challenge of the server
+	PreemptiveBasicAuth bool `toml:"preemptive_basic_auth"`
+
+	// Bearer token, or the path to a file containing it if APIKeyIsFile is set
+	APIKey       config.Secret `toml:"api_key"`
+	APIKeyIsFile bool          `toml:"api_key_is_file"`
+	AuthScheme   string        `toml:"auth_scheme"`
+
+	// OAuth2 client-credentials flow, replaces api_key and basic auth
+	OAuth2ClientID     string        `toml:"oauth2_client_id"`
+	OAuth2ClientSecret config.Secret `toml:"oauth2_client_secret"`
+	OAuth2TokenURL     string        `toml:"oauth2_token_url"`
+	OAuth2Scopes       []string      `toml:"oauth2_scopes"`
+
+	// SHA-256 fingerprint of the server certificate to pin
+	TLSCertFingerprint string `toml:"tls_cert_fingerprint"`
+	// PEM encoded CA certificates in addition to the ones of tls_ca
+	TLSCAPEM string `toml:"tls_ca_pem"`
+	// Hosts to skip the certificate verification for
+	TLSInsecureHosts []string `toml:"tls_insecure_hosts"`
+	tlsint.ClientConfig
+
+	Log telegraf.Logger `toml:"-"`
+
+	urls        []*url.URL
+	extraTags   map[*url.URL]map[string]string
+	timeouts    map[*url.URL]time.Duration
+	auth        map[*url.URL]*credentials
+	sockets     map[string]string
+	groupFilter filter.Filter
+	nameFilter  filter.Filter
+	preferCount filter.Filter
+	preferValue filter.Filter
+	client      *http.Client
+	transport   *http.Transport
+	insecure    *http.Transport
+	h2c         *http2.Transport
+	dnsCache    *dnsCache
+	fingerprint []byte
+
+	// Number of status requests and failed ones since the start
+	requests atomic.Int64
+	failures atomic.Int64
+
+	// Parent context of all requests, canceling it aborts in-flight requests
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Warnings that were already reported
+	warned     map[string]bool
+	warnedLock sync.Mutex
+
+	// Random sources of the delays by URL
+	jitterRand map[*url.URL]*rand.Rand
+	jitterLock sync.Mutex
+
+	// Status URLs that answered after the configured one returned 404
+	resolved     map[*url.URL]*url.URL
+	resolvedLock sync.Mutex
+
+	// Validators and status of the last response by status URL
+	statusCache     map[string]*cachedStatus
+	statusCacheLock sync.Mutex
+
+	// Counts of the previous gather by URL, metric and field
+	previous     map[counterKey]interface{}
+	previousLock sync.Mutex
+
+	// Uptime of the previous gather by URL for detecting its unit
+	uptimes     map[*url.URL]uptimeSample
+	uptimesLock sync.Mutex
+}
+
+// cachedStatus is a status kept for conditional requests
+type cachedStatus struct {
+	etag         string
+	lastModified string
+	stats        *AlertaStats
+}
+
+// metricKey identifies a metric within a status document
+type metricKey struct {
+	group string
+	name  string
+	typ   string
+}
+
+// requestTimings records the phases of the last request made with its trace
+type requestTimings struct {
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	durations    map[string]interface{}
+	lock         sync.Mutex
+}
+
+// clientTrace returns the trace to attach to the requests. Phases skipped
+// for reused connections are not reported.
+func (rt *requestTimings) clientTrace() *httptrace.ClientTrace {
+	// Record the time a phase took since its start
+	done := func(field string, start *time.Time) {
+		rt.lock.Lock()
+		defer rt.lock.Unlock()
+		if !start.IsZero() && rt.durations != nil {
+			rt.durations[field] = float64(time.Since(*start)) / float64(time.Millisecond)
+		}
+	}
+	// Record the start of a phase, only the first one counts for dialing
+	// several addresses in parallel
+	started := func(start *time.Time) {
+		rt.lock.Lock()
+		defer rt.lock.Unlock()
+		if start.IsZero() {
+			*start = time.Now()
+		}
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// Retries start from scratch
+			rt.lock.Lock()
+			defer rt.lock.Unlock()
+			rt.start = time.Now()
+			rt.dnsStart, rt.connectStart, rt.tlsStart = time.Time{}, time.Time{}, time.Time{}
+			rt.durations = make(map[string]interface{}, 4)
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { started(&rt.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { done("dns_ms", &rt.dnsStart) },
+		ConnectStart:         func(string, string) { started(&rt.connectStart) },
+		ConnectDone:          func(string, string, error) { done("connect_ms", &rt.connectStart) },
+		TLSHandshakeStart:    func() { started(&rt.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { done("tls_ms", &rt.tlsStart) },
+		GotFirstResponseByte: func() { done("ttfb_ms", &rt.start) },
+	}
+}
+
+// fields returns the durations of the phases in milliseconds
+func (rt *requestTimings) fields() map[string]interface{} {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	fields := make(map[string]interface{}, len(rt.durations))
+	for k, v := range rt.durations {
+		fields[k] = v
+	}
+	return fields
+}
+
+// counterKey identifies a cumulative field of a status metric
+type counterKey struct {
+	url   string
+	group string
+	name  string
+	field string
+}
+
+// URLTags are additional tags for the metrics of a single URL
+type URLTags struct {
+	URL  string            `toml:"url"`
+	Tags map[string]string `toml:"tags"`
+}
+
+// timeoutKey is the context key of the response timeout configured for the
+// URL being gathered
+type timeoutKey struct{}
+
+// uptimeSample is the uptime reported by a URL at the time it was gathered
+// together with the unit detected so far
+type uptimeSample struct {
+	value int64
+	at    time.Time
+	unit  time.Duration
+}
+
+// FieldPath extracts a custom field from the status response by a GJSON path
+type FieldPath struct {
+	Name string `toml:"name"`
+	Path string `toml:"path"`
+}
+
+// URLAuth are credentials and request settings for a single URL replacing
+// the top-level ones
+type URLAuth struct {
+	URL      string                    `toml:"url"`
+	Username config.Secret             `toml:"username"`
+	Password config.Secret             `toml:"password"`
+	APIKey   config.Secret             `toml:"api_key"`
+	Headers  map[string]*config.Secret `toml:"headers"`
+	Timeout  config.Duration           `toml:"timeout"`
+}
+
+// credentials are the resolved authentication settings of a URL
+type credentials struct {
+	username *config.Secret
+	password *config.Secret
+	apiKey   *config.Secret
+	headers  map[string]*config.Secret
+}
+
+// reservedTags are set by the plugin in addition to the URL tag and cannot be
+// overridden by url_tags
+var reservedTags = []string{"version", "metric_name", "metric_group", "metric_type"}
+
+// AlertaStats is the document returned by the Alerta status endpoint
+type AlertaStats struct {
+	Version string         `json:"version"`
+	Uptime  int64          `json:"uptime"`
+	Met     []AlertaMetric `json:"metrics"`
+	// Customer identifier at the default customer_path, a string or number
+	Customer json.RawMessage `json:"customer"`
+
+	// Start of the document as received if debug_include_raw is set
+	raw string
+	// Complete document as received if field_paths or a custom
+	// customer_path are configured
+	document []byte
+}
+
+// AlertaMetric is a single entry of the status metrics array
+type AlertaMetric struct {
+	Group     string `json:"group"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	TotalTime int64  `json:"totalTime"`
+
+	// Values and counts are integers but may be decimals in future versions
+	Value json.Number `json:"value"`
+	Count json.Number `json:"count"`
+
+	// Dropwizard statistics only reported by some Alerta versions
+	MeanRate *float64 `json:"meanRate"`
+	M1Rate   *float64 `json:"m1_rate"`
+	M5Rate   *float64 `json:"m5_rate"`
+	M15Rate  *float64 `json:"m15_rate"`
+	P50      *float64 `json:"p50"`
+	P75      *float64 `json:"p75"`
+	P95      *float64 `json:"p95"`
+	P98      *float64 `json:"p98"`
+	P99      *float64 `json:"p99"`
+	P999     *float64 `json:"p999"`
+
+	// Numeric attributes not known to the plugin, e.g. added by newer
+	// Alerta versions
+	extra map[string]json.Number
+}
+
+// knownAttributes are the attributes of a metric decoded into the fields of
+// AlertaMetric or not to be reported
+var knownAttributes = []string{
+	"group", "name", "type", "title", "description", "totalTime", "value", "count",
+	"meanRate", "m1_rate", "m5_rate", "m15_rate", "p50", "p75", "p95", "p98", "p99", "p999",
+}
+
+// UnmarshalJSON decodes the well-known attributes of the metric and keeps all
+// other numeric attributes to not lose values added in future versions
+func (m *AlertaMetric) UnmarshalJSON(data []byte) error {
+	type plain AlertaMetric
+	if err := json.Unmarshal(data, (*plain)(m)); err != nil {
+		return err
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return err
+	}
+	for k, raw := range attributes {
+		if choice.Contains(k, knownAttributes) || len(raw) == 0 {
+			continue
+		}
+		// Only numbers start with a digit or minus sign
+		if c := raw[0]; c != '-' && (c < '0' || c > '9') {
+			continue
+		}
+		if m.extra == nil {
+			m.extra = make(map[string]json.Number)
+		}
+		m.extra[k] = json.Number(raw)
+	}
+	return nil
+}
+
+// attributes returns the numeric attributes unknown to the plugin with their
+// names converted to snake case, e.g. "maxTime" to "max_time"
+func (m *AlertaMetric) attributes() map[string]interface{} {
+	attributes := make(map[string]interface{}, len(m.extra))
+	for k, v := range m.extra {
+		attributes[snakeCase(k)] = number(v)
+	}
+	return attributes
+}
+
+// snakeCase converts a camel case name to snake case
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// number returns the given value as integer if possible and as float
+// otherwise to keep the most precise representation. Absent values are zero.
+func number(n json.Number) interface{} {
+	if n == "" {
+		return int64(0)
+	}
+	if v, err := n.Int64(); err == nil {
+		return v
+	}
+	// The decoder already validated the number, so this can only fail for
+	// values out of range which are reported as infinity.
+	v, _ := n.Float64()
+	return v
+}
+
+// preference returns "count" or "value" if the count of a gauge or the value
+// of a timer or meter was selected to be reported instead of the fields of
+// its type and an empty string otherwise
+func (a *Alerta) preference(m *AlertaMetric) string {
+	switch m.Type {
+	case "gauge":
+		if a.preferCount != nil && a.preferCount.Match(m.Name) {
+			return "count"
+		}
+	case "timer", "meter":
+		if a.preferValue != nil && a.preferValue.Match(m.Name) {
+			return "value"
+		}
+	}
+	return ""
+}
+
+// value returns the value of a gauge or the count of other metrics for
+// reporting
+func (m *AlertaMetric) value() json.Number {
+	if m.Type == "gauge" {
+		return m.Value
+	}
+	return m.Count
+}
+
+// meanTime returns the average duration of a timer and false if the count is
+// zero
+func (m *AlertaMetric) meanTime() (float64, bool) {
+	count, err := m.Count.Float64()
+	if err != nil || count <= 0 {
+		return 0, false
+	}
+	return float64(m.TotalTime) / count, true
+}
+
+// statistics returns the Dropwizard statistics present in the metric. Absent
+// and zero values are skipped to not report statistics the server does not
+// provide.
+func (m *AlertaMetric) statistics() map[string]float64 {
+	candidates := map[string]*float64{
+		"mean_rate": m.MeanRate,
+		"m1_rate":   m.M1Rate,
+		"m5_rate":   m.M5Rate,
+		"m15_rate":  m.M15Rate,
+		"p50":       m.P50,
+		"p75":       m.P75,
+		"p95":       m.P95,
+		"p98":       m.P98,
+		"p99":       m.P99,
+		"p999":      m.P999,
+	}
+
+	stats := make(map[string]float64)
+	for k, v := range candidates {
+		if v != nil && *v != 0 {
+			stats[k] = *v
+		}
+	}
+	return stats
+}
+
+// AlertaCounts is the document returned by the Alerta alert count endpoint
+type AlertaCounts struct {
+	Total          int64            `json:"total"`
+	SeverityCounts map[string]int64 `json:"severityCounts"`
+	StatusCounts   map[string]int64 `json:"statusCounts"`
+}
+
+// AlertaHeartbeats is the document returned by the Alerta heartbeat endpoint
+type AlertaHeartbeats struct {
+	Heartbeats []AlertaHeartbeat `json:"heartbeats"`
+}
+
+// AlertaHeartbeat is a single entry of the heartbeats array
+type AlertaHeartbeat struct {
+	Origin      string    `json:"origin"`
+	Customer    string    `json:"customer"`
+	ReceiveTime time.Time `json:"receiveTime"`
+	Timeout     int64     `json:"timeout"`
+	Latency     int64     `json:"latency"`
+	Since       int64     `json:"since"`
+}
+
+// AlertaEnvironments is the document returned by the Alerta environments
+// endpoint
+type AlertaEnvironments struct {
+	Environments []AlertaEnvironment `json:"environments"`
+}
+
+// AlertaEnvironment is a single entry of the environments array
+type AlertaEnvironment struct {
+	Environment string `json:"environment"`
+	Count       int64  `json:"count"`
+}
+
+// AlertaBlackouts is the document returned by the Alerta blackouts endpoint
+type AlertaBlackouts struct {
+	Blackouts []AlertaBlackout `json:"blackouts"`
+}
+
+// AlertaBlackout is a single entry of the blackouts array
+type AlertaBlackout struct {
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+}
+
+// state returns if the blackout is "active", "expired" or "pending" at the
+// given time. A blackout whose end time has passed is expired regardless of
+// the reported status to notice stuck ones, otherwise the status of the
+// server is used and derived from the time window if missing.
+func (b *AlertaBlackout) state(now time.Time) string {
+	if !b.EndTime.IsZero() && !now.Before(b.EndTime) {
+		return "expired"
+	}
+	switch strings.ToLower(b.Status) {
+	case "active", "expired", "pending":
+		return strings.ToLower(b.Status)
+	}
+	if now.Before(b.StartTime) {
+		return "pending"
+	}
+	return "active"
+}
+
+// AlertaGroups is the document returned by the Alerta alerts endpoint when
+// grouping alerts. Each group holds the grouped value, either keyed by the
+// grouped field or by "value", and the number of alerts as "count".
+type AlertaGroups struct {
+	Groups []map[string]interface{} `json:"groups"`
+}
+
+// AlertaHealthcheck is the document returned by the Alerta healthcheck
+// endpoint with the overall status and optionally the status of single checks
+type AlertaHealthcheck struct {
+	Status string                       `json:"status"`
+	Checks map[string]healthcheckStatus `json:"checks"`
+}
+
+// healthcheckStatus is the status of a single check, given either as string
+// or as object with a "status" property
+type healthcheckStatus string
+
+func (s *healthcheckStatus) UnmarshalJSON(data []byte) error {
+	var status string
+	if err := json.Unmarshal(data, &status); err == nil {
+		*s = healthcheckStatus(status)
+		return nil
+	}
+
+	var check struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(data, &check); err != nil {
+		return fmt.Errorf("expected status string or object: %w", err)
+	}
+	*s = healthcheckStatus(check.Status)
+	return nil
+}
+
+// isHealthy returns true if the given status denotes a passing check
+func isHealthy(status string) bool {
+	switch strings.ToLower(status) {
+	case "ok", "healthy", "pass", "up":
+		return true
+	}
+	return false
+}
+
+// alertaGroup is the number of alerts with the same value of a field
+type alertaGroup struct {
+	value string
+	count int64
+}
+
+// httpStatusError is returned if a server answers with an unexpected status
+type httpStatusError struct {
+	address  string
+	status   string
+	code     int
+	location string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.location != "" {
+		return fmt.Sprintf("%s returned HTTP status %s redirecting to %s", e.address, e.status, e.location)
+	}
+	return fmt.Sprintf("%s returned HTTP status %s", e.address, e.status)
+}
+
+// alertaError is the envelope Alerta uses to report API errors, partly with
+// HTTP status 200
+type alertaError struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// statusDocument is the status response including a potential error envelope
+type statusDocument struct {
+	AlertaStats
+	alertaError
+
+	// Key of the metrics array if it differs from "metrics"
+	metricsKey string
+}
+
+// UnmarshalJSON decodes the status taking the metrics from the configured key
+func (d *statusDocument) UnmarshalJSON(data []byte) error {
+	type plain statusDocument
+	if d.metricsKey == "" || d.metricsKey == "metrics" {
+		return json.Unmarshal(data, (*plain)(d))
+	}
+
+	// Decode the document without the metrics so a "metrics" key of
+	// another type does not fail the decoding
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return err
+	}
+	metrics := attributes[d.metricsKey]
+	delete(attributes, d.metricsKey)
+	delete(attributes, "metrics")
+
+	rest, err := json.Marshal(attributes)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(rest, (*plain)(d)); err != nil {
+		return err
+	}
+	if metrics == nil {
+		return nil
+	}
+	if err := json.Unmarshal(metrics, &d.Met); err != nil {
+		return fmt.Errorf("decoding metrics from key %q failed: %w", d.metricsKey, err)
+	}
+	return nil
+}
+
+// err returns the error reported by the envelope or nil if there is none
+func (e *alertaError) err(address string) error {
+	if e.Status != "error" {
+		return nil
+	}
+	if e.Message == "" {
+		return fmt.Errorf("%s returned error without message", address)
+	}
+	return fmt.Errorf("%s returned error: %s", address, e.Message)
+}
+
+func (*Alerta) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Alerta) Init() error {
+	switch a.AuthScheme {
+	case "", "bearer", "api-key", "key":
+	default:
+		return fmt.Errorf("invalid auth_scheme %q", a.AuthScheme)
+	}
+	if a.OAuth2ClientID != "" || !a.OAuth2ClientSecret.Empty() || a.OAuth2TokenURL != "" {
+		if a.OAuth2ClientID == "" || a.OAuth2ClientSecret.Empty() || a.OAuth2TokenURL == "" {
+			return errors.New("oauth2_client_id, oauth2_client_secret and oauth2_token_url must be set together")
+		}
+	}
+
+	if a.MaxRetries < 0 || a.MaxRetries > maxRetriesLimit {
+		return fmt.Errorf("invalid max_retries %d, must be between 0 and %d", a.MaxRetries, maxRetriesLimit)
+	}
+	if a.RetryBackoff == 0 {
+		a.RetryBackoff = config.Duration(time.Second)
+	}
+
+	if a.MaxConcurrentRequests < 0 {
+		return fmt.Errorf("invalid max_concurrent_requests %d, must not be negative", a.MaxConcurrentRequests)
+	}
+	if a.URLJitter < 0 {
+		return fmt.Errorf("invalid url_jitter %s, must not be negative", time.Duration(a.URLJitter))
+	}
+
+	if a.MaxBodySize == 0 {
+		a.MaxBodySize = config.Size(defaultMaxBodySize)
+	}
+	if a.DebugIncludeRaw {
+		if a.DebugRawResponseSize == 0 {
+			a.DebugRawResponseSize = config.Size(defaultRawSize)
+		}
+		a.Log.Warn("Option debug_include_raw is enabled, the status responses are attached to every status metric! " +
+			"Only use this for debugging as it increases the size of metrics considerably and might expose sensitive data.")
+	}
+
+	if a.Measurement == "" {
+		a.Measurement = "alerta"
+	}
+	if a.MetricsJSONKey == "" {
+		a.MetricsJSONKey = "metrics"
+	}
+	if a.CustomerPath == "" {
+		a.CustomerPath = defaultCustomerPath
+	}
+
+	switch a.UptimeUnit {
+	case "":
+		a.UptimeUnit = "ms"
+	case "ms", "s", "auto":
+	default:
+		return fmt.Errorf("invalid uptime_unit %q, expected ms, s or auto", a.UptimeUnit)
+	}
+
+	switch a.HTTP2 {
+	case "":
+		a.HTTP2 = "auto"
+	case "auto", "off", "prior-knowledge":
+	default:
+		return fmt.Errorf("invalid http2 %q, expected auto, off or prior-knowledge", a.HTTP2)
+	}
+
+	if a.UserAgent == "" {
+		a.UserAgent = defaultUserAgent
+	}
+	if a.Accept == "" {
+		a.Accept = defaultAccept
+	}
+
+	switch a.Format {
+	case "":
+		a.Format = "json"
+	case "json", "prometheus":
+	default:
+		return fmt.Errorf("invalid format %q, expected json or prometheus", a.Format)
+	}
+
+	a.HTTPMethod = strings.ToUpper(a.HTTPMethod)
+	switch a.HTTPMethod {
+	case "":
+		a.HTTPMethod = http.MethodGet
+	case http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("invalid http_method %q, expected GET, POST or PUT", a.HTTPMethod)
+	}
+	if a.HTTPBody != "" && a.HTTPMethod == http.MethodGet {
+		return errors.New("http_body requires http_method POST or PUT")
+	}
+
+	if a.Path == "" {
+		a.Path = defaultStatusPath
+	}
+	// The metrics endpoint is served next to the status one
+	if a.Format == "prometheus" && a.Path == defaultStatusPath {
+		a.Path = defaultMetricsPath
+	}
+	a.Path = strings.TrimSuffix(a.Path, "/")
+
+	if a.AlertCountsPath == "" {
+		a.AlertCountsPath = defaultCountsPath
+	}
+	if a.HeartbeatsPath == "" {
+		a.HeartbeatsPath = defaultHeartbeats
+	}
+	if a.EnvironmentsPath == "" {
+		a.EnvironmentsPath = defaultEnvironments
+	}
+	if a.BlackoutsPath == "" {
+		a.BlackoutsPath = defaultBlackouts
+	}
+	if a.GroupByPath == "" {
+		a.GroupByPath = defaultGroupByPath
+	}
+	if a.HealthcheckPath == "" {
+		a.HealthcheckPath = defaultHealthcheck
+	}
+	if a.TopN < 0 {
+		return fmt.Errorf("invalid top_n %d, must not be negative", a.TopN)
+	}
+
+	if len(a.Groups) == 0 {
+		a.Groups = []string{"alerts"}
+	}
+	f, err := filter.Compile(a.Groups)
+	if err != nil {
+		return fmt.Errorf("invalid groups: %w", err)
+	}
+	a.groupFilter = f
+	nf, err := filter.NewIncludeExcludeFilter(a.MetricNameInclude, a.MetricNameExclude)
+	if err != nil {
+		return fmt.Errorf("invalid metric_name_include or metric_name_exclude: %w", err)
+	}
+	a.nameFilter = nf
+	for _, name := range a.PreferCount {
+		if choice.Contains(name, a.PreferValue) {
+			return fmt.Errorf("metric %q must not be listed in both prefer_count and prefer_value", name)
+		}
+	}
+	if a.preferCount, err = filter.Compile(a.PreferCount); err != nil {
+		return fmt.Errorf("invalid prefer_count: %w", err)
+	}
+	if a.preferValue, err = filter.Compile(a.PreferValue); err != nil {
+		return fmt.Errorf("invalid prefer_value: %w", err)
+	}
+
+	names := make(map[string]bool, len(a.FieldPaths))
+	for _, fp := range a.FieldPaths {
+		if fp.Name == "" || fp.Path == "" {
+			return fmt.Errorf("field_paths entry %q for path %q requires both name and path", fp.Name, fp.Path)
+		}
+		if names[fp.Name] {
+			return fmt.Errorf("duplicate field_paths entry for field %q", fp.Name)
+		}
+		if err := validatePath(fp.Path); err != nil {
+			return fmt.Errorf("invalid path %q of field_paths entry %q: %w", fp.Path, fp.Name, err)
+		}
+		names[fp.Name] = true
+	}
+	if err := validatePath(a.CustomerPath); err != nil {
+		return fmt.Errorf("invalid customer_path %q: %w", a.CustomerPath, err)
+	}
+	a.warned = make(map[string]bool)
+	a.jitterRand = make(map[*url.URL]*rand.Rand)
+	a.previous = make(map[counterKey]interface{})
+	a.uptimes = make(map[*url.URL]uptimeSample)
+	a.statusCache = make(map[string]*cachedStatus)
+
+	if a.TLSCertFingerprint != "" {
+		// Accept the colon-separated notation of e.g. openssl
+		fp, err := hex.DecodeString(strings.ReplaceAll(a.TLSCertFingerprint, ":", ""))
+		if err != nil || len(fp) != sha256.Size {
+			return fmt.Errorf("invalid tls_cert_fingerprint %q, expected a hex-encoded SHA-256 hash", a.TLSCertFingerprint)
+		}
+		a.fingerprint = fp
+	}
+
+	if len(a.TLSInsecureHosts) > 0 && a.InsecureSkipVerify {
+		return errors.New("tls_insecure_hosts has no effect if insecure_skip_verify is set")
+	}
+	for i, host := range a.TLSInsecureHosts {
+		canonical := canonicalHost(host)
+		if canonical == "" || strings.Contains(canonical, "/") || (strings.Contains(canonical, ":") && !isIPAddress(canonical)) {
+			return fmt.Errorf("invalid tls_insecure_hosts entry %q, expected a hostname or IP address without port", host)
+		}
+		a.TLSInsecureHosts[i] = canonical
+	}
+
+	if a.URLTag == "" {
+		a.URLTag = "url"
+	}
+	if !a.ExcludeURLTag && choice.Contains(a.URLTag, reservedTags) {
+		return fmt.Errorf("invalid url_tag %q, the tag is already used by the plugin", a.URLTag)
+	}
+	if a.GroupBy != "" && (a.GroupBy == a.URLTag || choice.Contains(a.GroupBy, reservedTags)) {
+		return fmt.Errorf("invalid group_by %q, the tag is already used by the plugin", a.GroupBy)
+	}
+
+	urls, templateTags, err := a.expandURLTemplate()
+	if err != nil {
+		return err
+	}
+
+	tagsByURL := make(map[string]map[string]string, len(a.URLTags))
+	for _, ut := range a.URLTags {
+		if _, found := tagsByURL[ut.URL]; found {
+			return fmt.Errorf("duplicate url_tags entry for %q", ut.URL)
+		}
+		for k := range ut.Tags {
+			if choice.Contains(k, reservedTags) || (k == a.URLTag && !a.ExcludeURLTag) {
+				return fmt.Errorf("url_tags for %q must not set the reserved tag %q", ut.URL, k)
+			}
+		}
+		tagsByURL[ut.URL] = ut.Tags
+	}
+
+	defaultAuth := a.credentials(&a.Username, &a.Password, &a.APIKey, "")
+	defaultAuth.headers = a.Headers
+	authByURL := make(map[string]*credentials, len(a.URLAuth))
+	for i := range a.URLAuth {
+		ua := &a.URLAuth[i]
+		if _, found := authByURL[ua.URL]; found {
+			return fmt.Errorf("duplicate url_auth entry for %q", ua.URL)
+		}
+		if ua.Timeout < 0 {
+			return fmt.Errorf("invalid timeout %s in url_auth for %q", time.Duration(ua.Timeout), ua.URL)
+		}
+
+		// Credentials are replaced as a whole to not mix a key of the
+		// defaults with basic auth of the URL or vice versa
+		auth := *defaultAuth
+		if !ua.Username.Empty() || !ua.Password.Empty() || !ua.APIKey.Empty() {
+			auth = *a.credentials(&ua.Username, &ua.Password, &ua.APIKey, ua.URL)
+		}
+		auth.headers = mergeHeaders(a.Headers, ua.Headers)
+		authByURL[ua.URL] = &auth
+	}
+
+	var netrc map[string]netrcEntry
+	if a.NetrcPath != "" {
+		netrc, err = readNetrc(a.NetrcPath)
+		if err != nil {
+			return fmt.Errorf("reading netrc_path failed: %w", err)
+		}
+	}
+
+	a.urls = make([]*url.URL, 0, len(urls))
+	a.extraTags = make(map[*url.URL]map[string]string, len(tagsByURL)+len(templateTags))
+	a.timeouts = make(map[*url.URL]time.Duration)
+	a.auth = make(map[*url.URL]*credentials, len(urls))
+	a.resolved = make(map[*url.URL]*url.URL)
+	a.sockets = make(map[string]string)
+	seen := make(map[string]*url.URL, len(urls))
+	// Duplicates ignored with dedupe_urls and the addresses kept instead
+	dropped := make(map[string]*url.URL)
+	timeoutsByURL := make(map[string]time.Duration, len(a.URLAuth))
+	for _, ua := range a.URLAuth {
+		if ua.Timeout > 0 {
+			timeoutsByURL[ua.URL] = time.Duration(ua.Timeout)
+		}
+	}
+	for i, u := range urls {
+		addr, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("unable to parse urls entry %d: %w", i, parseError(err))
+		}
+		switch addr.Scheme {
+		case "http", "https":
+			if addr.Host == "" {
+				return fmt.Errorf("missing host in address %q", sanitizeURL(addr))
+			}
+		case "unix":
+			socket, _, found := strings.Cut(addr.Path, ":")
+			if addr.Host != "" || !found || socket == "" {
+				return fmt.Errorf("invalid address %q, expected unix://<socket>:<path>", sanitizeURL(addr))
+			}
+			a.sockets[socketHost(socket)] = socket
+		default:
+			return fmt.Errorf("invalid scheme %q in address %q, expected http, https or unix", addr.Scheme, sanitizeURL(addr))
+		}
+		// Allow reverse-proxy prefixes in front of the status path as well
+		// as a trailing slash. Query parameters are kept as they are. Other
+		// methods than GET query arbitrary endpoints.
+		if a.HTTPMethod == http.MethodGet && !strings.HasSuffix(strings.TrimSuffix(addr.Path, "/"), a.Path) {
+			return fmt.Errorf("invalid path %q in address %q, expected it to end with %q", addr.Path, sanitizeURL(addr), a.Path)
+		}
+
+		// Gathering the same endpoint twice doubles the load and the metrics
+		key := normalizeURL(addr)
+		if first, found := seen[key]; found {
+			if !a.DedupeURLs {
+				return fmt.Errorf("duplicate address %q, it refers to the same endpoint as %q", sanitizeURL(addr), sanitizeURL(first))
+			}
+			a.Log.Warnf("Ignoring duplicate address %q, it refers to the same endpoint as %q", sanitizeURL(addr), sanitizeURL(first))
+			dropped[u] = first
+			continue
+		}
+		seen[key] = addr
+		a.urls = append(a.urls, addr)
+
+		// Tags of url_tags take precedence over the template variables
+		if tags, found := templateTags[u]; found {
+			a.extraTags[addr] = tags
+		}
+		if tags, found := tagsByURL[u]; found {
+			if vars, found := templateTags[u]; found {
+				merged := make(map[string]string, len(vars)+len(tags))
+				for k, v := range vars {
+					merged[k] = v
+				}
+				for k, v := range tags {
+					merged[k] = v
+				}
+				tags = merged
+			}
+			a.extraTags[addr] = tags
+			delete(tagsByURL, u)
+		}
+		if timeout, found := timeoutsByURL[u]; found {
+			a.timeouts[addr] = timeout
+		}
+		a.auth[addr] = defaultAuth
+		if auth, found := authByURL[u]; found {
+			a.auth[addr] = auth
+			delete(authByURL, u)
+		}
+		if auth := a.auth[addr]; netrc != nil && auth.username.Empty() && auth.password.Empty() {
+			if entry, found := lookupNetrc(netrc, addr.Hostname()); found {
+				withNetrc := *auth
+				username, password := config.NewSecret([]byte(entry.login)), config.NewSecret([]byte(entry.password))
+				withNetrc.username, withNetrc.password = &username, &password
+				a.auth[addr] = &withNetrc
+			}
+		}
+	}
+	// Settings of an ignored duplicate would be silently lost, so point to
+	// the address kept instead
+	for _, ut := range a.URLTags {
+		if _, unmatched := tagsByURL[ut.URL]; unmatched {
+			if kept, found := dropped[ut.URL]; found {
+				return fmt.Errorf("url_tags entry for %q refers to an ignored duplicate address, configure it for %q instead", ut.URL, sanitizeURL(kept))
+			}
+			return fmt.Errorf("url_tags entry for %q does not match any of the urls", ut.URL)
+		}
+	}
+	for _, ua := range a.URLAuth {
+		if _, unmatched := authByURL[ua.URL]; unmatched {
+			if kept, found := dropped[ua.URL]; found {
+				return fmt.Errorf("url_auth entry for %q refers to an ignored duplicate address, configure it for %q instead", ua.URL, sanitizeURL(kept))
+			}
+			return fmt.Errorf("url_auth entry for %q does not match any of the urls", ua.URL)
+		}
+	}
+
+	// Create an HTTP client that is re-used for each
+	// collection interval
+	// The context is needed by the client to fetch OAuth2 tokens
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	client, err := a.createHTTPClient()
+	if err != nil {
+		return err
+	}
+	a.client = client
+
+	return nil
+}
+
+// expandURLTemplate returns the configured URLs followed by the URLs expanded
+// from the template for each set of variables, together with the variables of
+// each expanded URL to be used as tags
+func (a *Alerta) expandURLTemplate() ([]string, map[string]map[string]string, error) {
+	if a.URLTemplate == "" {
+		if len(a.URLTemplateVars) > 0 {
+			return nil, nil, errors.New("url_template_vars requires url_template to be set")
+		}
+		return a.Urls, nil, nil
+	}
+	if len(a.URLTemplateVars) == 0 {
+		return nil, nil, errors.New("url_template requires at least one url_template_vars entry")
+	}
+
+	tmpl, err := template.New("url_template").Option("missingkey=error").Parse(a.URLTemplate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid url_template: %w", err)
+	}
+
+	urls := make([]string, 0, len(a.Urls)+len(a.URLTemplateVars))
+	urls = append(urls, a.Urls...)
+	tagsByURL := make(map[string]map[string]string, len(a.URLTemplateVars))
+	for i, vars := range a.URLTemplateVars {
+		for k := range vars {
+			if choice.Contains(k, reservedTags) || (k == a.URLTag && !a.ExcludeURLTag) {
+				return nil, nil, fmt.Errorf("url_template_vars entry %d must not set the reserved tag %q", i+1, k)
+			}
+		}
+
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			return nil, nil, fmt.Errorf("expanding url_template with entry %d of url_template_vars failed: %w", i+1, err)
+		}
+		u := buf.String()
+		if _, found := tagsByURL[u]; found || choice.Contains(u, a.Urls) {
+			return nil, nil, fmt.Errorf("url_template_vars entry %d expands to the duplicate URL %q", i+1, u)
+		}
+		urls = append(urls, u)
+		tagsByURL[u] = vars
+	}
+	return urls, tagsByURL, nil
+}
+
+// Start is a no-op as metrics are only collected in Gather, it is required to
+// get Stop called on shutdown.
+func (a *Alerta) Start(_ telegraf.Accumulator) error {
+	return nil
+}
+
+// Stop aborts all in-flight requests and closes the idle connections to not
+// delay the shutdown or reload of Telegraf by slow Alerta instances.
+func (a *Alerta) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	if a.transport != nil {
+		a.transport.CloseIdleConnections()
+	}
+	if a.insecure != nil {
+		a.insecure.CloseIdleConnections()
+	}
+	if a.h2c != nil {
+		a.h2c.CloseIdleConnections()
+	}
+}
+
+func (a *Alerta) Gather(acc telegraf.Accumulator) error {
+	var wg sync.WaitGroup
+
+	// Limit the number of in-flight requests if requested
+	var guard chan struct{}
+	if a.MaxConcurrentRequests > 0 {
+		guard = make(chan struct{}, a.MaxConcurrentRequests)
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	defer cancel()
+
+	// All points of the gather share the same timestamp to align the series
+	// of the URLs independent of their response times
+	gatherTime := time.Now()
+
+	// With fail_fast the first error aborts the remaining requests and is
+	// returned instead of being added to the accumulator
+	var firstErr error
+	var once sync.Once
+	report := func(err error) {
+		if err == nil {
+			return
+		}
+		if !a.FailFast {
+			acc.AddError(err)
+			return
+		}
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for _, addr := range a.urls {
+		wg.Add(1)
+		go func(addr *url.URL) {
+			defer wg.Done()
+			// Spread the requests of the URLs and agents over time, the
+			// delay does not occupy a slot of max_concurrent_requests
+			if delay := a.jitter(addr); delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+			}
+			if guard != nil {
+				select {
+				case guard <- struct{}{}:
+					defer func() { <-guard }()
+				case <-ctx.Done():
+				}
+			}
+			// Skip URLs not started before the gather was aborted
+			if a.FailFast && ctx.Err() != nil {
+				return
+			}
+			// Requests of URLs with their own timeout use it instead of
+			// the global one
+			ctx := ctx
+			if timeout, found := a.timeouts[addr]; found {
+				ctx = context.WithValue(ctx, timeoutKey{}, timeout)
+			}
+			auth := a.auth[addr]
+			if a.Format == "prometheus" {
+				report(a.gatherPrometheus(ctx, addr, auth, acc, gatherTime))
+			} else {
+				report(a.gatherURL(ctx, addr, auth, acc, gatherTime))
+			}
+			if a.AlertCounts {
+				report(a.gatherAlertCounts(ctx, addr, auth, acc, gatherTime))
+			}
+			if a.Heartbeats {
+				report(a.gatherHeartbeats(ctx, addr, auth, acc, gatherTime))
+			}
+			if a.Environments {
+				report(a.gatherEnvironments(ctx, addr, auth, acc, gatherTime))
+			}
+			if a.Blackouts {
+				report(a.gatherBlackouts(ctx, addr, auth, acc, gatherTime))
+			}
+			if a.GroupBy != "" {
+				report(a.gatherAlertGroups(ctx, addr, auth, acc, gatherTime))
+			}
+			if a.Healthcheck {
+				report(a.gatherHealthcheck(ctx, addr, auth, acc, gatherTime))
+			}
+		}(addr)
+	}
+
+	wg.Wait()
+
+	if a.GatherDuration {
+		fields := map[string]interface{}{
+			"gather_duration_ms": float64(time.Since(gatherTime)) / float64(time.Millisecond),
+		}
+		acc.AddGauge(a.Measurement+"_gather", fields, nil, gatherTime)
+	}
+
+	if a.RequestCounts {
+		fields := map[string]interface{}{
+			"requests": a.requests.Load(),
+			"errors":   a.failures.Load(),
+		}
+		acc.AddCounter(a.Measurement+"_requests", fields, nil, gatherTime)
+	}
+
+	return firstErr
+}
+
+// jitter returns a random delay below url_jitter for the next gather of the
+// URL. Every URL has a random source of its own, seeded by the URL and the
+// start of the agent, so the URLs of one agent as well as the same URL
+// gathered by different agents are spread independently.
+func (a *Alerta) jitter(addr *url.URL) time.Duration {
+	if a.URLJitter <= 0 {
+		return 0
+	}
+
+	a.jitterLock.Lock()
+	defer a.jitterLock.Unlock()
+
+	source, found := a.jitterRand[addr]
+	if !found {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(addr.String()))
+		source = rand.New(rand.NewSource(int64(h.Sum64()) ^ time.Now().UnixNano()))
+		a.jitterRand[addr] = source
+	}
+	return time.Duration(source.Int63n(int64(a.URLJitter)))
+}
+
+func (a *Alerta) createHTTPClient() (*http.Client, error) {
+	// Accept the plain version numbers as well, e.g. "1.2" for "TLS12"
+	if v := a.TLSMinVersion; strings.HasPrefix(v, "1.") {
+		a.TLSMinVersion = "TLS" + strings.ReplaceAll(v, ".", "")
+	}
+
+	tlsCfg, err := a.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// The TLS settings are only created if any option besides the minimum
+	// version is set, honor the version on its own as well.
+	if tlsCfg == nil && a.TLSMinVersion != "" {
+		version, err := tlsint.ParseTLSVersion(a.TLSMinVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls_min_version: %w", err)
+		}
+		tlsCfg = &tls.Config{MinVersion: version}
+	}
+
+	// Inline CAs are added to those of tls_ca and like these replace the
+	// system CAs otherwise, e.g. to not depend on files in containers.
+	if a.TLSCAPEM != "" {
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{MinVersion: tlsint.TLSMinVersionDefault}
+		}
+		pool := tlsCfg.RootCAs
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(a.TLSCAPEM)) {
+			return nil, errors.New("invalid tls_ca_pem, no certificate found")
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	// The pinned fingerprint replaces the verification of the certificate
+	// chain so self-signed certificates can be pinned as well.
+	if len(a.fingerprint) > 0 {
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{MinVersion: tlsint.TLSMinVersionDefault}
+		}
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = a.verifyFingerprint
+	}
+
+	if a.ResponseTimeout < config.Duration(time.Second) {
+		a.ResponseTimeout = config.Duration(time.Second * 5)
+	}
+	// The client's timeout is only a safeguard, the deadline of each request
+	// is set by its context allowing longer timeouts for individual URLs.
+	clientTimeout := time.Duration(a.ResponseTimeout)
+	for _, timeout := range a.timeouts {
+		if timeout > clientTimeout {
+			clientTimeout = timeout
+		}
+	}
+
+	if a.MaxIdleConns == 0 {
+		a.MaxIdleConns = 100
+	}
+	// Allow keeping a connection per URL in case all point to the same host
+	if a.MaxIdleConnsPerHost == 0 {
+		a.MaxIdleConnsPerHost = len(a.urls)
+	}
+	if a.IdleConnTimeout == 0 {
+		a.IdleConnTimeout = config.Duration(90 * time.Second)
+	}
+
+	// Use the defaults of net/http, the response timeout still caps the
+	// whole request
+	if a.DialTimeout == 0 {
+		a.DialTimeout = config.Duration(30 * time.Second)
+	}
+	if a.TLSHandshakeTimeout == 0 {
+		a.TLSHandshakeTimeout = config.Duration(10 * time.Second)
+	}
+
+	dialer := a.dialer()
+	resolver := net.DefaultResolver
+	if a.DNSNameserver != "" {
+		// Default to the DNS port if the nameserver is given without one
+		nameserver := a.DNSNameserver
+		if _, _, err := net.SplitHostPort(nameserver); err != nil {
+			nameserver = net.JoinHostPort(strings.Trim(nameserver, "[]"), "53")
+		}
+		host, _, err := net.SplitHostPort(nameserver)
+		if err != nil || host == "" {
+			return nil, fmt.Errorf("invalid dns_nameserver %q", a.DNSNameserver)
+		}
+		nameserverDialer := &net.Dialer{Timeout: dialer.Timeout}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return nameserverDialer.DialContext(ctx, network, nameserver)
+			},
+		}
+		dialer.Resolver = resolver
+	}
+	dialContext := dialer.DialContext
+	if a.DNSCacheTTL > 0 {
+		a.dnsCache = &dnsCache{
+			ttl:     time.Duration(a.DNSCacheTTL),
+			lookup:  resolver.LookupHost,
+			log:     a.Log,
+			entries: make(map[string]*dnsEntry),
+		}
+		dialContext = a.dnsCache.dialContext(dialer.DialContext)
+	}
+
+	// Honor HTTP_PROXY, HTTPS_PROXY and NO_PROXY unless a proxy is configured
+	proxy := http.ProxyFromEnvironment
+	if a.HTTPProxyURL != "" {
+		proxyURL, err := url.Parse(a.HTTPProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse http_proxy_url: %w", parseError(err))
+		}
+		switch proxyURL.Scheme {
+		case "socks5", "socks5h":
+			// Connections are tunneled through the SOCKS5 proxy by the
+			// dialer, credentials are taken from the URL
+			if proxyURL.Host == "" {
+				return nil, errors.New("missing host of SOCKS5 proxy in http_proxy_url")
+			}
+			if len(a.ProxyHeaders) > 0 {
+				return nil, errors.New("proxy_headers are not supported for SOCKS5 proxies")
+			}
+			socks, err := netproxy.FromURL(proxyURL, dialer)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create SOCKS5 dialer for http_proxy_url: %w", err)
+			}
+			contextDialer, ok := socks.(netproxy.ContextDialer)
+			if !ok {
+				return nil, errors.New("SOCKS5 dialer for http_proxy_url does not support contexts")
+			}
+			dialContext = contextDialer.DialContext
+			proxy = nil
+		default:
+			proxy = http.ProxyURL(proxyURL)
+		}
+	}
+
+	// Requests to unix sockets are sent to a placeholder host, connect to
+	// the socket instead and never go through a proxy
+	if len(a.sockets) > 0 {
+		proxyDialContext := dialContext
+		dialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if host, _, err := net.SplitHostPort(address); err == nil {
+				if socket, found := a.sockets[host]; found {
+					return dialer.DialContext(ctx, "unix", socket)
+				}
+			}
+			return proxyDialContext(ctx, network, address)
+		}
+		if httpProxy := proxy; httpProxy != nil {
+			proxy = func(req *http.Request) (*url.URL, error) {
+				if _, found := a.sockets[req.URL.Hostname()]; found {
+					return nil, nil
+				}
+				return httpProxy(req)
+			}
+		}
+	}
+
+	a.transport = &http.Transport{
+		DialContext:         dialContext,
+		TLSClientConfig:     tlsCfg,
+		TLSHandshakeTimeout: time.Duration(a.TLSHandshakeTimeout),
+		Proxy:               proxy,
+		MaxIdleConns:        a.MaxIdleConns,
+		MaxIdleConnsPerHost: a.MaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(a.IdleConnTimeout),
+		DisableKeepAlives:   a.DisableKeepAlives,
+		// The custom dialer disables HTTP/2 unless forced
+		ForceAttemptHTTP2: a.HTTP2 != "off",
+	}
+	// Credentials of the proxy URL are sent as basic auth by the transport
+	if len(a.ProxyHeaders) > 0 {
+		a.transport.GetProxyConnectHeader = a.proxyConnectHeader
+	}
+	switch a.HTTP2 {
+	case "off":
+		// A non-nil map prevents the upgrade to HTTP/2 via ALPN
+		a.transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	case "prior-knowledge":
+		// Speak HTTP/2 right away on cleartext connections (h2c). HTTP
+		// proxies cannot be used for these connections.
+		a.h2c = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, address string, _ *tls.Config) (net.Conn, error) {
+				return dialContext(ctx, network, address)
+			},
+		}
+		a.transport.RegisterProtocol("http", a.h2c)
+	}
+	client := &http.Client{
+		Transport: a.transport,
+		Timeout:   clientTimeout,
+	}
+	// Return redirects, e.g. to a login page, as non-200 response to not
+	// mask authentication problems
+	if !a.FollowRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	// Requests to the hosts exempted from the certificate verification use a
+	// separate transport to not relax the verification of the other hosts.
+	// The pinned fingerprint is not checked for these hosts either.
+	if len(a.TLSInsecureHosts) > 0 {
+		a.insecure = a.transport.Clone()
+		if a.insecure.TLSClientConfig == nil {
+			a.insecure.TLSClientConfig = &tls.Config{MinVersion: tlsint.TLSMinVersionDefault}
+		}
+		a.insecure.TLSClientConfig.InsecureSkipVerify = true
+		a.insecure.TLSClientConfig.VerifyPeerCertificate = nil
+		if a.h2c != nil {
+			a.insecure.RegisterProtocol("http", a.h2c)
+		}
+		client.Transport = &hostTransport{
+			hosts:    a.TLSInsecureHosts,
+			insecure: a.insecure,
+			secure:   a.transport,
+		}
+	}
+
+	if a.OAuth2ClientID == "" {
+		return client, nil
+	}
+
+	// Tokens are fetched and refreshed using the client above, so the TLS
+	// and proxy settings apply to the token endpoint as well. The token is
+	// reused until it expires.
+	source := oauth2.ReuseTokenSource(nil, &oauth2TokenSource{
+		ctx:    context.WithValue(a.ctx, oauth2.HTTPClient, client),
+		plugin: a,
+	})
+	oauthClient := &http.Client{
+		Transport:     &oauth2.Transport{Source: source, Base: client.Transport},
+		Timeout:       client.Timeout,
+		CheckRedirect: client.CheckRedirect,
+	}
+	return oauthClient, nil
+}
+
+// oauth2TokenSource fetches tokens via the OAuth2 client-credentials flow,
+// resolving the client secret for every token request so rotated secrets are
+// picked up and the secret is not kept in memory in between
+type oauth2TokenSource struct {
+	ctx    context.Context
+	plugin *Alerta
+}
+
+func (s *oauth2TokenSource) Token() (*oauth2.Token, error) {
+	secret, err := s.plugin.OAuth2ClientSecret.Get()
+	if err != nil {
+		return nil, fmt.Errorf("getting oauth2_client_secret failed: %w", err)
+	}
+	defer releaseSecret(secret)
+
+	cfg := clientcredentials.Config{
+		ClientID:     s.plugin.OAuth2ClientID,
+		ClientSecret: string(secret),
+		TokenURL:     s.plugin.OAuth2TokenURL,
+		Scopes:       s.plugin.OAuth2Scopes,
+	}
+	return cfg.Token(s.ctx)
+}
+
+// dialer returns the dialer used to establish connections to the servers
+func (a *Alerta) dialer() *net.Dialer {
+	// A zero keep-alive period enables the keep-alive with the default period
+	// of the dialer, so a negative one is needed to disable it
+	keepAlive := time.Duration(a.KeepAlive)
+	if keepAlive <= 0 {
+		keepAlive = -1
+	}
+	return &net.Dialer{
+		Timeout:   time.Duration(a.DialTimeout),
+		KeepAlive: keepAlive,
+	}
+}
+
+// dnsCache keeps the addresses of the resolved hosts for the TTL so new
+// connections to the same host do not cause another lookup
+type dnsCache struct {
+	ttl    time.Duration
+	lookup func(ctx context.Context, host string) ([]string, error)
+	log    telegraf.Logger
+
+	entries map[string]*dnsEntry
+	lock    sync.Mutex
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// resolve returns the cached addresses of the host and looks them up again
+// once they expired. If the lookup fails, the expired addresses are used
+// until the host can be resolved again.
+func (c *dnsCache) resolve(ctx context.Context, host string) ([]string, error) {
+	c.lock.Lock()
+	entry, found := c.entries[host]
+	c.lock.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.lookup(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no addresses found for %q", host)
+	}
+	if err != nil {
+		if found {
+			c.log.Debugf("Resolving %q failed, using the expired addresses: %v", host, err)
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.entries[host] = &dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.lock.Unlock()
+	return addrs, nil
+}
+
+// dialContext resolves the host of the address via the cache and connects to
+// the resolved addresses in turn using the given dial function
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || host == "" || isIPAddress(host) {
+			return dial(ctx, network, address)
+		}
+		addrs, err := c.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
+
+// urlTags returns the tags of all metrics gathered from the given URL
+func (a *Alerta) urlTags(addr *url.URL, address string) map[string]string {
+	extra := a.extraTags[addr]
+
+	tags := make(map[string]string, len(extra)+2)
+	for k, v := range extra {
+		tags[k] = v
+	}
+	if !a.ExcludeURLTag {
+		tags[a.URLTag] = address
+	}
+	return tags
+}
+
+// proxyConnectHeader returns the headers of the CONNECT requests to the
+// proxy, resolving secret-store references for every tunnel so rotated
+// values are picked up
+func (a *Alerta) proxyConnectHeader(context.Context, *url.URL, string) (http.Header, error) {
+	header := make(http.Header, len(a.ProxyHeaders))
+	for k, secret := range a.ProxyHeaders {
+		if secret == nil {
+			continue
+		}
+		value, err := secret.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting proxy header %q failed: %w", k, err)
+		}
+		header.Set(k, string(value))
+		releaseSecret(value)
+	}
+	return header, nil
+}
+
+// hostTransport sends the requests to the given hosts via the insecure
+// transport and all others via the secure one
+type hostTransport struct {
+	hosts    []string
+	insecure http.RoundTripper
+	secure   http.RoundTripper
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if choice.Contains(canonicalHost(req.URL.Hostname()), t.hosts) {
+		return t.insecure.RoundTrip(req)
+	}
+	return t.secure.RoundTrip(req)
+}
+
+// verifyFingerprint checks the SHA-256 hash of the leaf certificate presented
+// by the server against the pinned fingerprint
+func (a *Alerta) verifyFingerprint(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	sum := sha256.Sum256(rawCerts[0])
+	if subtle.ConstantTimeCompare(sum[:], a.fingerprint) != 1 {
+		return fmt.Errorf("certificate fingerprint %s does not match tls_cert_fingerprint", hex.EncodeToString(sum[:]))
+	}
+	return nil
+}
+
+// addDown reports the endpoint as down after a failed request
+func (a *Alerta) addDown(acc telegraf.Accumulator, gatherTime time.Time, addr *url.URL, address string, responseTime time.Duration, err error) {
+	a.failures.Add(1)
+
+	fields := map[string]interface{}{"up": 0}
+	if responseTime > 0 {
+		fields["response_time_ms"] = float64(responseTime) / float64(time.Millisecond)
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		fields["http_status_code"] = statusErr.code
+	}
+	acc.AddFields(a.statusMeasurement(), a.prefixFields(fields), a.urlTags(addr, address), gatherTime)
+}
+
+// statusMeasurement returns the measurement of the status point, which holds
+// the metadata of the server only if the measurements are split
+func (a *Alerta) statusMeasurement() string {
+	if a.SplitMeasurements && a.Format != "prometheus" {
+		return a.Measurement + "_meta"
+	}
+	return a.Measurement
+}
+
+// gatherPrometheus emits the metrics of the Prometheus endpoint of the URL
+// together with the reachability of the endpoint
+func (a *Alerta) gatherPrometheus(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	address := sanitizeURL(addr)
+	a.Log.Debugf("Gathering metrics from %s", address)
+
+	a.requests.Add(1)
+	requested := time.Now()
+	var metrics []telegraf.Metric
+	_, responseTime, err := a.fetch(ctx, a.statusURL(addr), auth, nil, nil, &metrics)
+	if err != nil {
+		a.addDown(acc, gatherTime, addr, address, responseTime, err)
+		return err
+	}
+
+	tags := a.urlTags(addr, address)
+	fields := map[string]interface{}{
+		"up":               1,
+		"response_time_ms": float64(responseTime) / float64(time.Millisecond),
+	}
+	acc.AddFields(a.Measurement, a.prefixFields(fields), tags, gatherTime)
+
+	// The labels of the metrics are kept, the tags of the URL take
+	// precedence. Metrics without a timestamp of their own got the time of
+	// parsing and are aligned with the gather, too.
+	for _, m := range metrics {
+		t := m.Time()
+		if !t.Before(requested) {
+			t = gatherTime
+		}
+		metricTags := m.Tags()
+		for k, v := range tags {
+			metricTags[k] = v
+		}
+		fields := a.prefixFields(m.Fields())
+		switch m.Type() {
+		case telegraf.Counter:
+			acc.AddCounter(a.Measurement, fields, metricTags, t)
+		case telegraf.Gauge:
+			acc.AddGauge(a.Measurement, fields, metricTags, t)
+		case telegraf.Summary:
+			acc.AddSummary(a.Measurement, fields, metricTags, t)
+		case telegraf.Histogram:
+			acc.AddHistogram(a.Measurement, fields, metricTags, t)
+		default:
+			acc.AddFields(a.Measurement, fields, metricTags, t)
+		}
+	}
+	return nil
+}
+
+func (a *Alerta) gatherURL(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	address := sanitizeURL(addr)
+	a.Log.Debugf("Gathering status from %s", address)
+
+	a.requests.Add(1)
+	var timings *requestTimings
+	if a.TraceTimings {
+		timings = &requestTimings{}
+		ctx = httptrace.WithClientTrace(ctx, timings.clientTrace())
+	}
+	stats, responseTime, err := a.fetchStatus(ctx, addr, auth)
+	if err != nil {
+		a.addDown(acc, gatherTime, addr, address, responseTime, err)
+		return err
+	}
+
+	tags := a.urlTags(addr, address)
+	tags["version"] = stats.Version
+	// Separate the series of multi-tenant servers, tags configured for the
+	// URL take precedence
+	if customer := a.customer(stats); customer != "" {
+		if _, found := tags["customer"]; !found {
+			tags["customer"] = customer
+		}
+	}
+	fields := map[string]interface{}{
+		"up":               1,
+		"uptime":           stats.Uptime,
+		"uptime_seconds":   a.uptimeSeconds(addr, stats.Uptime),
+		"response_time_ms": float64(responseTime) / float64(time.Millisecond),
+		"version":          stats.Version,
+	}
+	if a.DebugIncludeRaw {
+		fields["raw_response"] = stats.raw
+	}
+	if timings != nil {
+		for k, v := range timings.fields() {
+			fields[k] = v
+		}
+	}
+	// Allow to compare versions numerically, build metadata such as in
+	// "9.0.1-dev" is ignored.
+	if v, err := semver.NewVersion(strings.TrimPrefix(stats.Version, "v")); err == nil {
+		fields["version_major"] = v.Major
+		fields["version_minor"] = v.Minor
+		fields["version_patch"] = v.Patch
+	} else {
+		a.Log.Debugf("Unable to parse version %q from %s: %v", stats.Version, address, err)
+	}
+
+	// Notice over-aggressive filters, the gather still succeeds
+	if len(stats.Met) > 0 && !a.hasMetrics(stats) {
+		if a.WarnOnNoMetrics {
+			a.warnOnce("All metrics from %s were removed by the groups and metric name filters", address)
+		} else {
+			a.Log.Debugf("All %d metrics from %s were removed by the groups and metric name filters", len(stats.Met), address)
+		}
+	}
+
+	// Sum of all gauges of the alerts group, i.e. the number of alerts
+	var totalAlerts interface{} = int64(0)
+	// Number of metrics turned into fields to notice filtering or changes of
+	// the schema
+	var collected int64
+	seen := make(map[metricKey]AlertaMetric, len(stats.Met))
+	for _, m := range stats.Met {
+		if !a.groupFilter.Match(m.Group) {
+			a.warnSkippedGroup(m.Group)
+			continue
+		}
+		if !a.nameFilter.Match(m.Name) {
+			continue
+		}
+
+		// A metric reported twice would overwrite the first one or, for
+		// tagged metrics, produce two points of the same series, so keep the
+		// first one in the document.
+		key := metricKey{group: m.Group, name: m.Name, typ: m.Type}
+		if first, found := seen[key]; found {
+			// The values may change with every gather, so only warn once
+			// per metric
+			a.warnOnceFor(fmt.Sprintf("duplicate %s %s %s %s", address, m.Group, m.Name, m.Type),
+				"Dropping duplicate metric %q of type %q in group %q from %s with value %s, keeping the first one with value %s",
+				m.Name, m.Type, m.Group, address, m.value(), first.value())
+			continue
+		}
+		seen[key] = m
+		switch m.Type {
+		case "timer", "meter", "gauge":
+			collected++
+		}
+		if m.Group == "alerts" && m.Type == "gauge" {
+			totalAlerts = sum(totalAlerts, number(m.Value))
+		}
+
+		if a.TagMetrics || a.SplitMeasurements {
+			a.addTaggedMetric(acc, gatherTime, addr, m, tags)
+			continue
+		}
+
+		// Different name and group combinations might map to the same
+		// field, e.g. "a_b" in group "c" and "a" in group "b_c". Keep the
+		// field of the first metric in the document and drop later ones.
+		name := m.Name + "_" + m.Group
+		add := func(key string, value interface{}) {
+			if _, found := fields[key]; found {
+				a.warnOnce("Dropping field %q of metric %q in group %q from %s as it conflicts with an existing field",
+					key, m.Name, m.Group, address)
+				return
+			}
+			fields[key] = value
+		}
+		switch a.preference(&m) {
+		case "value":
+			add(name, number(m.Value))
+			for k, v := range m.attributes() {
+				add(name+"_"+k, v)
+			}
+			continue
+		case "count":
+			add(name+"_count", number(m.Count))
+			for k, v := range m.attributes() {
+				add(name+"_"+k, v)
+			}
+			continue
+		}
+		switch m.Type {
+		case "timer":
+			add(name+"_count", number(m.Count))
+			add(name+"_total_time", m.TotalTime)
+			if mean, ok := m.meanTime(); ok {
+				add(name+"_mean_time", mean)
+			}
+			for k, v := range m.statistics() {
+				add(name+"_"+k, v)
+			}
+			for k, v := range a.deltas(addr, m) {
+				add(name+"_"+k, v)
+			}
+			for k, v := range m.attributes() {
+				add(name+"_"+k, v)
+			}
+		case "meter":
+			add(name+"_count", number(m.Count))
+			for k, v := range m.statistics() {
+				add(name+"_"+k, v)
+			}
+			for k, v := range a.deltas(addr, m) {
+				add(name+"_"+k, v)
+			}
+			for k, v := range m.attributes() {
+				add(name+"_"+k, v)
+			}
+		case "gauge":
+			add(name, number(m.Value))
+			for k, v := range m.attributes() {
+				add(name+"_"+k, v)
+			}
+		default:
+			a.Log.Debugf("Skipping metric %q of unsupported type %q from %s", name, m.Type, address)
+		}
+	}
+	for _, fp := range a.FieldPaths {
+		value, ok := fieldPathValue(stats.document, fp.Path)
+		if !ok {
+			a.Log.Debugf("Skipping field %q as path %q has no scalar value in the status from %s", fp.Name, fp.Path, address)
+			continue
+		}
+		if _, found := fields[fp.Name]; found {
+			a.warnOnce("Dropping field %q of field_paths from %s as it conflicts with an existing field", fp.Name, address)
+			continue
+		}
+		fields[fp.Name] = value
+	}
+	if _, found := fields["metrics_collected"]; found {
+		a.warnOnce("Dropping field \"metrics_collected\" from %s as it conflicts with an existing field", address)
+	} else {
+		fields["metrics_collected"] = collected
+	}
+	if a.ComputeTotals {
+		if _, found := fields["sum_alerts"]; found {
+			a.warnOnce("Dropping field \"sum_alerts\" from %s as it conflicts with an existing field", address)
+		} else {
+			fields["sum_alerts"] = totalAlerts
+		}
+	}
+	acc.AddFields(a.statusMeasurement(), a.prefixFields(fields), tags, gatherTime)
+
+	return nil
+}
+
+// gatherAlertCounts emits the number of alerts per severity and status
+func (a *Alerta) gatherAlertCounts(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	endpoint := a.endpointURL(addr, a.AlertCountsPath)
+
+	var doc struct {
+		AlertaCounts
+		alertaError
+	}
+	if _, err := a.fetchJSON(ctx, endpoint, auth, &doc); err != nil {
+		return err
+	}
+	if err := doc.err(sanitizeURL(endpoint)); err != nil {
+		return err
+	}
+
+	// Severities and statuses share some names such as "unknown", so prefix
+	// those to keep both.
+	fields := map[string]interface{}{"total": doc.Total}
+	for k, v := range doc.SeverityCounts {
+		if _, found := doc.StatusCounts[k]; found {
+			k = "severity_" + k
+		}
+		fields[k] = v
+	}
+	for k, v := range doc.StatusCounts {
+		if _, found := doc.SeverityCounts[k]; found {
+			k = "status_" + k
+		}
+		fields[k] = v
+	}
+	acc.AddGauge(a.Measurement+"_alerts", fields, a.urlTags(addr, sanitizeURL(addr)), gatherTime)
+
+	return nil
+}
+
+// gatherHeartbeats emits the state of the heartbeats of all origins
+func (a *Alerta) gatherHeartbeats(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	endpoint := a.endpointURL(addr, a.HeartbeatsPath)
+
+	var doc struct {
+		AlertaHeartbeats
+		alertaError
+	}
+	if _, err := a.fetchJSON(ctx, endpoint, auth, &doc); err != nil {
+		return err
+	}
+	if err := doc.err(sanitizeURL(endpoint)); err != nil {
+		return err
+	}
+
+	baseTags := a.urlTags(addr, sanitizeURL(addr))
+	for _, hb := range doc.Heartbeats {
+		// A heartbeat is stale if the origin did not send a new one within
+		// its timeout, as of the time of the point
+		stale := 0
+		if gatherTime.Sub(hb.ReceiveTime) > time.Duration(hb.Timeout)*time.Second {
+			stale = 1
+		}
+
+		tags := make(map[string]string, len(baseTags)+2)
+		for k, v := range baseTags {
+			tags[k] = v
+		}
+		tags["origin"] = hb.Origin
+		if hb.Customer != "" {
+			tags["customer"] = hb.Customer
+		}
+
+		fields := map[string]interface{}{
+			"latency": hb.Latency,
+			"since":   hb.Since,
+			"timeout": hb.Timeout,
+			"stale":   stale,
+		}
+		acc.AddGauge(a.Measurement+"_heartbeats", fields, tags, gatherTime)
+	}
+
+	return nil
+}
+
+// gatherEnvironments emits the number of alerts per environment
+func (a *Alerta) gatherEnvironments(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	endpoint := a.endpointURL(addr, a.EnvironmentsPath)
+
+	var doc struct {
+		AlertaEnvironments
+		alertaError
+	}
+	if _, err := a.fetchJSON(ctx, endpoint, auth, &doc); err != nil {
+		return err
+	}
+	if err := doc.err(sanitizeURL(endpoint)); err != nil {
+		return err
+	}
+	if len(doc.Environments) == 0 {
+		a.Log.Debugf("No environments returned by %s", sanitizeURL(endpoint))
+		return nil
+	}
+
+	baseTags := a.urlTags(addr, sanitizeURL(addr))
+	for _, env := range doc.Environments {
+		tags := make(map[string]string, len(baseTags)+1)
+		for k, v := range baseTags {
+			tags[k] = v
+		}
+		tags["environment"] = env.Environment
+
+		acc.AddGauge(a.Measurement+"_environments", map[string]interface{}{"count": env.Count}, tags, gatherTime)
+	}
+
+	return nil
+}
+
+// gatherBlackouts emits the number of active and expired blackouts
+func (a *Alerta) gatherBlackouts(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	endpoint := a.endpointURL(addr, a.BlackoutsPath)
+
+	var doc struct {
+		AlertaBlackouts
+		alertaError
+	}
+	if _, err := a.fetchJSON(ctx, endpoint, auth, &doc); err != nil {
+		return err
+	}
+	if err := doc.err(sanitizeURL(endpoint)); err != nil {
+		return err
+	}
+
+	// Pending blackouts are only part of the total
+	var active, expired int64
+	for i := range doc.Blackouts {
+		switch doc.Blackouts[i].state(gatherTime) {
+		case "active":
+			active++
+		case "expired":
+			expired++
+		}
+	}
+
+	fields := map[string]interface{}{
+		"active":  active,
+		"expired": expired,
+		"total":   int64(len(doc.Blackouts)),
+	}
+	acc.AddGauge(a.Measurement+"_blackouts", fields, a.urlTags(addr, sanitizeURL(addr)), gatherTime)
+
+	return nil
+}
+
+// gatherAlertGroups emits the number of alerts per value of the group_by
+// field limited to the top_n largest groups
+func (a *Alerta) gatherAlertGroups(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	endpoint := a.endpointURL(addr, a.GroupByPath)
+	query := endpoint.Query()
+	query.Set("group-by", a.GroupBy)
+	endpoint.RawQuery = query.Encode()
+
+	var doc struct {
+		AlertaGroups
+		alertaError
+	}
+	if _, err := a.fetchJSON(ctx, endpoint, auth, &doc); err != nil {
+		return err
+	}
+	if err := doc.err(sanitizeURL(endpoint)); err != nil {
+		return err
+	}
+
+	groups := make([]alertaGroup, 0, len(doc.Groups))
+	for _, g := range doc.Groups {
+		value, found := g[a.GroupBy]
+		if !found {
+			value = g["value"]
+		}
+		count, ok := g["count"].(float64)
+		if value == nil || !ok {
+			a.Log.Debugf("Skipping invalid group %v from %s", g, sanitizeURL(endpoint))
+			continue
+		}
+		groups = append(groups, alertaGroup{value: groupValue(value), count: int64(count)})
+	}
+
+	// Keep the largest groups, break ties by value to get a stable selection
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].value < groups[j].value
+	})
+	if a.TopN > 0 && len(groups) > a.TopN {
+		a.Log.Debugf("Dropping %d of %d groups from %s exceeding top_n", len(groups)-a.TopN, len(groups), sanitizeURL(endpoint))
+		groups = groups[:a.TopN]
+	}
+
+	baseTags := a.urlTags(addr, sanitizeURL(addr))
+	for _, g := range groups {
+		tags := make(map[string]string, len(baseTags)+1)
+		for k, v := range baseTags {
+			tags[k] = v
+		}
+		tags[a.GroupBy] = g.value
+
+		acc.AddGauge(a.Measurement+"_alert_groups", map[string]interface{}{"count": g.count}, tags, gatherTime)
+	}
+
+	return nil
+}
+
+// groupValue returns the string representation of a grouped value. Lists such
+// as the services of an alert are joined by commas.
+func groupValue(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	parts := make([]string, 0, len(list))
+	for _, v := range list {
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, ",")
+}
+
+// gatherHealthcheck emits the result of the healthcheck. An unreachable or
+// failing endpoint is reported as unhealthy in addition to the error.
+func (a *Alerta) gatherHealthcheck(ctx context.Context, addr *url.URL, auth *credentials, acc telegraf.Accumulator, gatherTime time.Time) error {
+	endpoint := a.endpointURL(addr, a.HealthcheckPath)
+	tags := a.urlTags(addr, sanitizeURL(addr))
+
+	var doc AlertaHealthcheck
+	if _, err := a.fetchJSON(ctx, endpoint, auth, &doc); err != nil {
+		acc.AddGauge(a.Measurement+"_healthcheck", map[string]interface{}{"healthy": 0}, tags, gatherTime)
+		return err
+	}
+
+	// Without an overall status the server is healthy if all checks pass
+	healthy := isHealthy(doc.Status) || (doc.Status == "" && len(doc.Checks) > 0)
+	fields := make(map[string]interface{}, len(doc.Checks)+1)
+	for name, status := range doc.Checks {
+		passing := isHealthy(string(status))
+		if doc.Status == "" && !passing {
+			healthy = false
+		}
+		fields["check_"+name] = boolToInt(passing)
+	}
+	fields["healthy"] = boolToInt(healthy)
+	acc.AddGauge(a.Measurement+"_healthcheck", fields, tags, gatherTime)
+
+	return nil
+}
+
+// boolToInt returns 1 for true and 0 for false
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// endpointURL returns the URL of the given API endpoint on the server of the
+// status URL. The API root is the status URL without the status path, so
+// reverse-proxy prefixes and query parameters are kept.
+func (a *Alerta) endpointURL(addr *url.URL, path string) *url.URL {
+	status := a.statusURL(addr)
+	endpoint := *status
+	root := strings.TrimSuffix(strings.TrimSuffix(status.Path, "/"), a.Path)
+	endpoint.Path = root + path
+	endpoint.RawPath = ""
+	return &endpoint
+}
+
+// statusURL returns the status URL that last answered for the configured one
+func (a *Alerta) statusURL(addr *url.URL) *url.URL {
+	a.resolvedLock.Lock()
+	defer a.resolvedLock.Unlock()
+
+	if resolved, found := a.resolved[addr]; found {
+		return resolved
+	}
+	return addr
+}
+
+// fetchStatus fetches the status from the configured URL or, with
+// auto_detect_path, from the alternative layout if the URL returns 404.
+// Current Alerta versions serve the API below "/api" while older versions
+// and some deployments serve it at the root, so the alternative is the URL
+// with the "/api" prefix added or removed. The URL that answered is used for
+// subsequent gathers and the other endpoints until it returns 404 as well.
+func (a *Alerta) fetchStatus(ctx context.Context, addr *url.URL, auth *credentials) (*AlertaStats, time.Duration, error) {
+	current := a.statusURL(addr)
+	stats, responseTime, err := a.fetchStats(ctx, current, auth)
+
+	var statusErr *httpStatusError
+	if !a.AutoDetectPath || !errors.As(err, &statusErr) || statusErr.code != http.StatusNotFound {
+		return stats, responseTime, err
+	}
+
+	alternative := addr
+	if current == addr {
+		alternative = a.alternativeURL(addr)
+	}
+	a.Log.Debugf("%s returned HTTP status %s, trying %s", sanitizeURL(current), statusErr.status, sanitizeURL(alternative))
+	stats, altResponseTime, altErr := a.fetchStats(ctx, alternative, auth)
+	if altErr != nil {
+		return nil, responseTime, fmt.Errorf("%w, trying %s failed as well: %v", err, sanitizeURL(alternative), altErr)
+	}
+
+	a.resolvedLock.Lock()
+	a.resolved[addr] = alternative
+	a.resolvedLock.Unlock()
+	a.Log.Infof("Using %s for %s", sanitizeURL(alternative), sanitizeURL(addr))
+
+	return stats, altResponseTime, nil
+}
+
+// alternativeURL returns the status URL with the "/api" prefix of the status
+// path added, or removed if already present
+func (a *Alerta) alternativeURL(addr *url.URL) *url.URL {
+	alternative := *addr
+	root := strings.TrimSuffix(strings.TrimSuffix(addr.Path, "/"), a.Path)
+	if strings.HasSuffix(root, "/api") {
+		root = strings.TrimSuffix(root, "/api")
+	} else {
+		root += "/api"
+	}
+	alternative.Path = root + a.Path
+	alternative.RawPath = ""
+	return &alternative
+}
+
+// warnSkippedGroup reports a metric group excluded by the groups filter
+func (a *Alerta) warnSkippedGroup(group string) {
+	a.warnOnce("Skipping metric group %q, add it to \"groups\" to collect it", group)
+}
+
+// warnOnce logs the given warning only the first time it occurs to not flood
+// the log on every gather
+func (a *Alerta) warnOnce(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	a.warnOnceFor(msg, "%s", msg)
+}
+
+// warnOnceFor logs the given warning only the first time a warning with the
+// given key occurs, e.g. for warnings containing values that change between
+// gathers
+func (a *Alerta) warnOnceFor(key, format string, args ...interface{}) {
+	a.warnedLock.Lock()
+	defer a.warnedLock.Unlock()
+
+	if a.warned[key] {
+		return
+	}
+	a.warned[key] = true
+	a.Log.Warnf(format, args...)
+}
+
+// prefixFields prepends field_prefix to the keys of the given status fields
+func (a *Alerta) prefixFields(fields map[string]interface{}) map[string]interface{} {
+	if a.FieldPrefix == "" {
+		return fields
+	}
+
+	prefixed := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		prefixed[a.FieldPrefix+k] = v
+	}
+	return prefixed
+}
+
+// uptimeSeconds converts the uptime reported by the given URL to seconds. With
+// uptime_unit set to auto, the unit is detected by comparing the increase of
+// the uptime to the time passed since the previous gather: milliseconds
+// advance about 1000 times faster than the clock, seconds at the same pace.
+// Until the unit could be detected, e.g. in the first gather, milliseconds
+// are assumed as reported by Alerta.
+func (a *Alerta) uptimeSeconds(addr *url.URL, uptime int64) float64 {
+	switch a.UptimeUnit {
+	case "s":
+		return float64(uptime)
+	case "ms":
+		return float64(uptime) / 1000
+	}
+
+	a.uptimesLock.Lock()
+	defer a.uptimesLock.Unlock()
+
+	now := timeNow()
+	unit := time.Millisecond
+	if prev, found := a.uptimes[addr]; found {
+		unit = prev.unit
+		// Skip restarts and unchanged statuses, e.g. of conditional requests
+		elapsed := now.Sub(prev.at).Seconds()
+		if delta := uptime - prev.value; delta > 0 && elapsed >= 1 {
+			// Use the geometric mean of both rates as boundary
+			if float64(delta)/elapsed < math.Sqrt(1000) {
+				unit = time.Second
+			} else {
+				unit = time.Millisecond
+			}
+		}
+	}
+	a.uptimes[addr] = uptimeSample{value: uptime, at: now, unit: unit}
+
+	return float64(uptime) * unit.Seconds()
+}
+
+// deltas returns the change of the cumulative fields of a timer or meter
+// since the previous gather if report_deltas is enabled. Fields seen for the
+// first time are skipped as there is nothing to compare with. A value lower
+// than the previous one means the counter was reset, e.g. by a server
+// restart, so the value itself is the change since then.
+func (a *Alerta) deltas(addr *url.URL, m AlertaMetric) map[string]interface{} {
+	if !a.ReportDeltas {
+		return nil
+	}
+
+	current := map[string]interface{}{"count": number(m.Count)}
+	if m.Type == "timer" {
+		current["total_time"] = m.TotalTime
+	}
+
+	a.previousLock.Lock()
+	defer a.previousLock.Unlock()
+
+	deltas := make(map[string]interface{}, len(current))
+	for field, value := range current {
+		key := counterKey{url: addr.String(), group: m.Group, name: m.Name, field: field}
+		prev, found := a.previous[key]
+		a.previous[key] = value
+		if !found {
+			continue
+		}
+		deltas[field+"_delta"] = delta(prev, value)
+	}
+	return deltas
+}
+
+// delta returns the difference between two counts, as integer if both are
+// integers, or the current value if the counter was reset
+func delta(prev, current interface{}) interface{} {
+	p, pok := prev.(int64)
+	c, cok := current.(int64)
+	if pok && cok {
+		if c < p {
+			return c
+		}
+		return c - p
+	}
+
+	pf, cf := toFloat(prev), toFloat(current)
+	if cf < pf {
+		return cf
+	}
+	return cf - pf
+}
+
+// sum returns the sum of two values returned by number, as integer if both
+// are integers
+func sum(x, y interface{}) interface{} {
+	xi, xok := x.(int64)
+	yi, yok := y.(int64)
+	if xok && yok {
+		return xi + yi
+	}
+	return toFloat(x) + toFloat(y)
+}
+
+// toFloat converts a value returned by number to float
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// addTaggedMetric emits a single status metric as its own point tagged with
+// the metric's name, group and type. Counts and times of timers and meters
+// are emitted as counter while values of gauges as well as the statistics
+// derived from timers and meters are emitted as gauge. Both points share the
+// same tags, so they form one series with untyped outputs. With split
+// measurements the points are emitted to a measurement of their own.
+func (a *Alerta) addTaggedMetric(acc telegraf.Accumulator, gatherTime time.Time, addr *url.URL, m AlertaMetric, baseTags map[string]string) {
+	counters := make(map[string]interface{})
+	gauges := make(map[string]interface{})
+	switch preference := a.preference(&m); {
+	case preference == "value":
+		gauges["value"] = number(m.Value)
+	case preference == "count":
+		gauges["count"] = number(m.Count)
+	case m.Type == "timer":
+		counters["count"] = number(m.Count)
+		counters["total_time"] = m.TotalTime
+		if mean, ok := m.meanTime(); ok {
+			gauges["mean_time"] = mean
+		}
+		for k, v := range m.statistics() {
+			gauges[k] = v
+		}
+		for k, v := range a.deltas(addr, m) {
+			gauges[k] = v
+		}
+	case m.Type == "meter":
+		counters["count"] = number(m.Count)
+		for k, v := range m.statistics() {
+			gauges[k] = v
+		}
+		for k, v := range a.deltas(addr, m) {
+			gauges[k] = v
+		}
+	case m.Type == "gauge":
+		gauges["value"] = number(m.Value)
+	default:
+		a.Log.Debugf("Skipping metric %q of unsupported type %q", m.Name+"_"+m.Group, m.Type)
+		return
+	}
+	for k, v := range m.attributes() {
+		_, isCounter := counters[k]
+		_, isGauge := gauges[k]
+		if isCounter || isGauge {
+			a.warnOnce("Dropping attribute %q of metric %q in group %q as it conflicts with an existing field", k, m.Name, m.Group)
+			continue
+		}
+		gauges[k] = v
+	}
+
+	tags := make(map[string]string, len(baseTags)+3)
+	for k, v := range baseTags {
+		tags[k] = v
+	}
+	tags["metric_name"] = m.Name
+	tags["metric_group"] = m.Group
+	tags["metric_type"] = m.Type
+
+	measurement := a.Measurement
+	if a.SplitMeasurements {
+		measurement += "_metric"
+	}
+	if len(counters) > 0 {
+		acc.AddCounter(measurement, a.prefixFields(counters), tags, gatherTime)
+	}
+	if len(gauges) > 0 {
+		acc.AddGauge(measurement, a.prefixFields(gauges), tags, gatherTime)
+	}
+}
+
+// fetchStats queries the status endpoint and validates the returned document.
+// The returned duration is the response time as reported by fetchJSON.
+func (a *Alerta) fetchStats(ctx context.Context, addr *url.URL, auth *credentials) (*AlertaStats, time.Duration, error) {
+	// Decode the status and a potential error envelope in one go
+	doc := statusDocument{metricsKey: a.MetricsJSONKey}
+
+	// Ask the server to only send the status if it changed since the last
+	// response and reuse the previous status otherwise
+	key := addr.String()
+	var conditions http.Header
+	var cached *cachedStatus
+	if a.ConditionalRequests {
+		a.statusCacheLock.Lock()
+		cached = a.statusCache[key]
+		a.statusCacheLock.Unlock()
+	}
+	if cached != nil {
+		conditions = make(http.Header)
+		if cached.etag != "" {
+			conditions.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			conditions.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	address := sanitizeURL(addr)
+	// Keep the document for the debug field and the custom field paths
+	raw := &prefixBuffer{size: int(a.DebugRawResponseSize)}
+	var document bytes.Buffer
+	var writers []io.Writer
+	if a.DebugIncludeRaw {
+		writers = append(writers, raw)
+	}
+	if a.keepDocument() {
+		writers = append(writers, &document)
+	}
+	var rawWriter io.Writer
+	if len(writers) > 0 {
+		rawWriter = io.MultiWriter(writers...)
+	}
+	header, responseTime, err := a.fetch(ctx, addr, auth, conditions, rawWriter, &doc)
+	if cached != nil && errors.Is(err, errNotModified) {
+		a.Log.Debugf("Status from %s not modified, reusing the previous one", address)
+		return cached.stats, responseTime, nil
+	}
+	if err != nil {
+		return nil, responseTime, err
+	}
+
+	if err := doc.err(address); err != nil {
+		return nil, responseTime, err
+	}
+
+	stats := &doc.AlertaStats
+	if a.DebugIncludeRaw {
+		stats.raw = raw.String()
+	}
+	if a.keepDocument() {
+		stats.document = document.Bytes()
+	}
+	if stats.Version == "" {
+		return nil, responseTime, fmt.Errorf("%s returned no version in status", address)
+	}
+	if a.RequireMetrics && !a.hasMetrics(stats) {
+		return nil, responseTime, fmt.Errorf("%s returned no metrics for groups %v", address, a.Groups)
+	}
+
+	if a.ConditionalRequests {
+		etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+		a.statusCacheLock.Lock()
+		if etag != "" || lastModified != "" {
+			a.statusCache[key] = &cachedStatus{etag: etag, lastModified: lastModified, stats: stats}
+		} else {
+			delete(a.statusCache, key)
+		}
+		a.statusCacheLock.Unlock()
+	}
+
+	return stats, responseTime, nil
+}
+
+// fetchJSON queries the given endpoint and decodes the JSON response into v.
+// The returned duration is the time until the response headers were received
+// and is zero if no response arrived at all.
+func (a *Alerta) fetchJSON(ctx context.Context, addr *url.URL, auth *credentials, v interface{}) (time.Duration, error) {
+	_, responseTime, err := a.fetch(ctx, addr, auth, nil, nil, v)
+	return responseTime, err
+}
+
+// fetch works like fetchJSON but sends the given conditional headers and
+// returns the headers of the response. If the server answers a conditional
+// request with 304, errNotModified is returned. A body in the Prometheus
+// exposition format is parsed instead of JSON if v is a metric slice.
+func (a *Alerta) fetch(ctx context.Context, addr *url.URL, auth *credentials, conditions http.Header, raw io.Writer, v interface{}) (http.Header, time.Duration, error) {
+	// Never expose credentials contained in the URL in errors
+	address := sanitizeURL(addr)
+
+	expectedType := "application/json"
+	metrics, isPrometheus := v.(*[]telegraf.Metric)
+	if isPrometheus {
+		expectedType = "text/plain"
+	}
+
+	// The configured method and body only apply to the status requests, the
+	// other endpoints are always queried using GET
+	method := http.MethodGet
+	var reqBody io.Reader
+	if _, isStatus := v.(*statusDocument); isStatus || isPrometheus {
+		method = a.HTTPMethod
+		if a.HTTPBody != "" {
+			reqBody = strings.NewReader(a.HTTPBody)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL(addr).String(), reqBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to create request for %s: %w", address, err)
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if addr.Scheme == "unix" {
+		req.Host = "localhost"
+	}
+
+	// Headers configured explicitly take precedence over the user agent and
+	// the accepted type
+	req.Header.Set("User-Agent", a.UserAgent)
+	if isPrometheus {
+		req.Header.Set("Accept", "text/plain;version=0.0.4")
+	} else {
+		req.Header.Set("Accept", a.Accept)
+	}
+	// Secrets are resolved for every request to pick up rotated values, a
+	// failure only affects the current URL
+	if err := setRequestHeaders(req, auth.headers); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", address, err)
+	}
+	challenged, err := a.setRequestAuth(req, auth)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", address, err)
+	}
+	for k, values := range conditions {
+		req.Header[k] = values
+	}
+
+	// Setting the header disables the transparent decompression of the
+	// transport, so the body is decoded in decodeBody.
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	resp, responseTime, err := a.do(req)
+
+	// Repeat the request with the credentials if the server asks for them
+	if err == nil && challenged && isBasicChallenge(resp) {
+		drainBody(resp.Body)
+
+		retry := req.Clone(ctx)
+		if err := setBasicAuth(retry, auth.username, auth.password); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", address, err)
+		}
+		a.Log.Debugf("%s requested basic auth, repeating the request with credentials", address)
+		var retryTime time.Duration
+		resp, retryTime, err = a.do(retry)
+		responseTime += retryTime
+	}
+	if err != nil {
+		// The client only redacts the password of the URL in its errors
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = address
+		}
+		return nil, 0, fmt.Errorf("error making HTTP request to %s: %w", address, err)
+	}
+	defer drainBody(resp.Body)
+
+	if resp.StatusCode == http.StatusNotModified && len(conditions) > 0 {
+		return resp.Header, responseTime, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		statusErr := &httpStatusError{address: address, status: resp.Status, code: resp.StatusCode}
+		if location, err := resp.Location(); err == nil {
+			statusErr.location = sanitizeURL(location)
+		}
+		return nil, responseTime, statusErr
+	}
+
+	contentType := strings.Split(resp.Header.Get("Content-Type"), ";")[0]
+	if contentType != expectedType && !a.InsecureParseAnyContentType {
+		return nil, responseTime, fmt.Errorf("%s returned unexpected content type %s", address, contentType)
+	}
+
+	var body io.Reader = resp.Body
+	if a.ResponseBodyLimit > 0 {
+		body = io.LimitReader(body, a.ResponseBodyLimit)
+	}
+	reader, err := decodeBody(resp, body)
+	if err != nil {
+		return nil, responseTime, fmt.Errorf("unable to decode body from %s: %w", address, err)
+	}
+	defer reader.Close()
+
+	var limited io.Reader = &limitedReader{r: reader, n: int64(a.MaxBodySize)}
+	if raw != nil {
+		limited = io.TeeReader(limited, raw)
+	}
+	if isPrometheus {
+		buf, err := io.ReadAll(limited)
+		if err != nil {
+			return nil, responseTime, fmt.Errorf("%s: %w", address, err)
+		}
+		parser := prometheus.Parser{Header: resp.Header}
+		if *metrics, err = parser.Parse(buf); err != nil {
+			return nil, responseTime, fmt.Errorf("unable to parse response from %s: %w", address, err)
+		}
+		return resp.Header, responseTime, nil
+	}
+	if err := json.NewDecoder(limited).Decode(v); err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			return nil, responseTime, fmt.Errorf("%s: %w", address, err)
+		}
+		return nil, responseTime, fmt.Errorf("unable to decode response from %s: %w", address, err)
+	}
+
+	return resp.Header, responseTime, nil
+}
+
+// hasMetrics checks if the status contains metrics of the configured groups
+// and names
+func (a *Alerta) hasMetrics(stats *AlertaStats) bool {
+	for _, m := range stats.Met {
+		if a.groupFilter.Match(m.Group) && a.nameFilter.Match(m.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveParams are query parameters possibly carrying credentials
+var sensitiveParams = []string{"access_token", "api-key", "api_key", "apikey", "key", "password", "secret", "token"}
+
+// requestURL returns the HTTP URL to request for the given address. Addresses
+// of the form "unix://<socket>:<path>" are mapped to the placeholder host of
+// the socket.
+func requestURL(addr *url.URL) *url.URL {
+	if addr.Scheme != "unix" {
+		return addr
+	}
+	socket, path, _ := strings.Cut(addr.Path, ":")
+	return &url.URL{
+		Scheme:   "http",
+		Host:     socketHost(socket),
+		Path:     path,
+		RawQuery: addr.RawQuery,
+	}
+}
+
+// normalizeURL returns the given address in a form comparable to detect
+// duplicates, i.e. without user information, default port and trailing slash
+// and with the host in lower case
+func normalizeURL(addr *url.URL) string {
+	host := canonicalHost(addr.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	switch port := addr.Port(); {
+	case port == "":
+	case addr.Scheme == "http" && port == "80":
+	case addr.Scheme == "https" && port == "443":
+	default:
+		host += ":" + port
+	}
+	normalized := url.URL{
+		Scheme:   addr.Scheme,
+		Host:     host,
+		Path:     strings.TrimSuffix(addr.Path, "/"),
+		RawQuery: addr.RawQuery,
+	}
+	return normalized.String()
+}
+
+// canonicalHost returns the host in lower case and IP addresses in their
+// canonical form, e.g. "2001:db8::1" for "[2001:DB8:0::1]", keeping the zone
+// of IPv6 addresses
+func canonicalHost(host string) string {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	// Interface names of zones are case-sensitive
+	address, zone, found := strings.Cut(host, "%")
+	host = strings.ToLower(address)
+	if found {
+		host += "%" + zone
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.String()
+	}
+	return host
+}
+
+// isIPAddress checks if the host is an IP address, optionally with zone
+func isIPAddress(host string) bool {
+	_, err := netip.ParseAddr(host)
+	return err == nil
+}
+
+// socketHost returns the placeholder host of a unix socket. Each socket gets
+// its own host so the transport does not share connections between sockets.
+func socketHost(socket string) string {
+	sum := sha256.Sum256([]byte(socket))
+	return hex.EncodeToString(sum[:8]) + ".sock"
+}
+
+// parseError strips the URL from errors of url.Parse as the unparsable URL
+// might contain credentials
+func parseError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// sanitizeURL returns the URL suitable for logs, errors and tags, i.e. without
+// user information and with the values of sensitive query parameters redacted
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+
+	if clean.RawQuery == "" {
+		return clean.String()
+	}
+
+	query := clean.Query()
+	redacted := false
+	for k := range query {
+		if choice.Contains(strings.ToLower(k), sensitiveParams) {
+			query[k] = []string{"xxxxx"}
+			redacted = true
+		}
+	}
+	// Keep the original query as is unless something was redacted
+	if redacted {
+		clean.RawQuery = query.Encode()
+	}
+	return clean.String()
+}
+
+// drainBody reads the remainder of the body before closing it. The decoders
+// stop at the end of the document, so without a Content-Length, e.g. for
+// chunked responses of proxies, the terminating chunk would be left unread and
+// the connection could not be reused.
+func drainBody(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainSize))
+	body.Close()
+}
+
+// decodeBody returns a reader decompressing the given body according to the
+// Content-Encoding of the response
+func decodeBody(resp *http.Response, body io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// validatePath checks the syntax of a GJSON path as gjson silently returns no
+// result for malformed paths. Components must not be empty, brackets and the
+// quotes of queries must be balanced, escapes must not dangle and modifiers
+// must exist.
+func validatePath(path string) error {
+	closing := map[byte]byte{')': '(', ']': '[', '}': '{'}
+	var open []byte
+	var quoted bool
+	start := 0
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '\\':
+			if i == len(path)-1 {
+				return errors.New("dangling escape at the end")
+			}
+			i++
+		case quoted:
+			quoted = c != '"'
+		case c == '"' && len(open) > 0:
+			quoted = true
+		case c == '(' || c == '[' || c == '{':
+			open = append(open, c)
+		case closing[c] != 0:
+			if len(open) == 0 || open[len(open)-1] != closing[c] {
+				return fmt.Errorf("unbalanced %q at position %d", c, i)
+			}
+			open = open[:len(open)-1]
+		case (c == '.' || c == '|') && len(open) == 0:
+			if err := validatePathComponent(path[start:i]); err != nil {
+				return err
+			}
+			start = i + 1
+		}
+	}
+	if quoted {
+		return errors.New("unterminated string")
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("unclosed %q", open[len(open)-1])
+	}
+	return validatePathComponent(path[start:])
+}
+
+// validatePathComponent checks a single component of a GJSON path
+func validatePathComponent(component string) error {
+	if component == "" {
+		return errors.New("empty path component")
+	}
+	if strings.HasPrefix(component, "@") {
+		name, _, _ := strings.Cut(component[1:], ":")
+		if !gjson.ModifierExists(name, nil) {
+			return fmt.Errorf("unknown modifier %q", name)
+		}
+	}
+	return nil
+}
+
+// fieldPathValue returns the scalar value at the given GJSON path of the
+// document and false if the path does not exist or points to an object, array
+// or null
+func fieldPathValue(document []byte, path string) (interface{}, bool) {
+	result := gjson.GetBytes(document, path)
+	switch result.Type {
+	case gjson.Number:
+		return number(json.Number(result.Raw)), true
+	case gjson.String:
+		return result.Str, true
+	case gjson.True, gjson.False:
+		return result.Bool(), true
+	}
+	return nil, false
+}
+
+// keepDocument reports whether the complete status document is needed after
+// decoding. The customer at the default path is decoded with the status, so
+// the document is only kept for paths that cannot be decoded this way.
+func (a *Alerta) keepDocument() bool {
+	return len(a.FieldPaths) > 0 || a.CustomerPath != defaultCustomerPath
+}
+
+// customer returns the customer identifier of the status or an empty string
+// if there is none
+func (a *Alerta) customer(stats *AlertaStats) string {
+	if a.CustomerPath == defaultCustomerPath {
+		return scalarString(gjson.ParseBytes(stats.Customer))
+	}
+	return customerValue(stats.document, a.CustomerPath)
+}
+
+// customerValue returns the customer identifier at the given GJSON path of
+// the document or an empty string if there is none
+func customerValue(document []byte, path string) string {
+	return scalarString(gjson.GetBytes(document, path))
+}
+
+// scalarString returns string and number values as string and an empty
+// string for all other types
+func scalarString(result gjson.Result) string {
+	switch result.Type {
+	case gjson.String, gjson.Number:
+		return result.String()
+	}
+	return ""
+}
+
+// prefixBuffer keeps the first size bytes written to it and discards the rest
+type prefixBuffer struct {
+	buf  []byte
+	size int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if n := b.size - len(b.buf); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+		b.buf = append(b.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+// String returns the kept bytes, dropping a rune cut off at the end
+func (b *prefixBuffer) String() string {
+	return strings.ToValidUTF8(string(b.buf), "")
+}
+
+// limitedReader fails with errBodyTooLarge once more than n bytes were read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+	// Read one byte more than allowed to detect exceeding the limit
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// do sends the request and retries transient failures, i.e. connection errors,
+// 5xx and 429 responses, up to MaxRetries times with exponential backoff. The
+// returned duration is the response time of the last attempt.
+//
+// Every attempt gets its own deadline of ResponseTimeout, or the timeout
+// configured for the gathered URL, derived from the request's context. The
+// deadline also covers reading the body and is released when the returned
+// response body is closed.
+func (a *Alerta) do(req *http.Request) (*http.Response, time.Duration, error) {
+	timeout := time.Duration(a.ResponseTimeout)
+	if t, ok := req.Context().Value(timeoutKey{}).(time.Duration); ok {
+		timeout = t
+	}
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		attemptReq := req.WithContext(ctx)
+		// Every attempt needs a fresh copy of the body
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return nil, 0, err
+			}
+			attemptReq.Body = body
+		}
+		start := time.Now()
+		resp, err := a.client.Do(attemptReq)
+		elapsed := time.Since(start)
+		if err != nil {
+			cancel()
+		} else {
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		}
+
+		if attempt >= a.MaxRetries || !isTransient(resp, err) {
+			return resp, elapsed, err
+		}
+
+		wait := a.backoff(attempt, timeout)
+		reason := err
+		if resp != nil {
+			// Honor the rate-limit hint of the server but do not wait longer
+			// than a single request may take.
+			if delay, ok := retryAfter(resp); ok {
+				wait = delay
+				if wait > timeout {
+					wait = timeout
+				}
+			}
+
+			drainBody(resp.Body)
+			reason = fmt.Errorf("HTTP status %s", resp.Status)
+		}
+		a.Log.Warnf("Request to %s failed: %v; retrying in %s", sanitizeURL(req.URL), reason, wait)
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, 0, req.Context().Err()
+		}
+	}
+}
+
+// cancelOnClose releases the context of a request once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// backoff returns the delay before the given retry attempt, doubling the
+// base delay for every attempt and adding up to 50% of random jitter. Like
+// for Retry-After the delay is capped at the limit, i.e. the time a single
+// request may take.
+func (a *Alerta) backoff(attempt int, limit time.Duration) time.Duration {
+	delay := time.Duration(a.RetryBackoff)
+	if delay <= 0 {
+		return 0
+	}
+	// Stop doubling once the limit is reached to not overflow
+	for i := 0; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a 429
+// response. The header may contain a number of seconds or an HTTP-date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func isTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// credentials returns the given credentials of the top-level configuration
+// or of the url_auth entry of the given URL
+func (a *Alerta) credentials(username, password, apiKey *config.Secret, u string) *credentials {
+	if !apiKey.Empty() && !username.Empty() && !password.Empty() {
+		if u == "" {
+			a.Log.Debug("Both api_key and username/password are set, using api_key and falling back to basic auth if it is empty")
+		} else {
+			a.Log.Debugf("Both api_key and username/password are set for %q, using api_key and falling back to basic auth if it is empty", u)
+		}
+	}
+	return &credentials{username: username, password: password, apiKey: apiKey}
+}
+
+// netrcEntry is the login of a machine in a netrc file
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// readNetrc parses the machines of the given netrc file, the "default" entry
+// is stored with an empty machine name. A leading "~/" of the path refers to
+// the home directory of the user.
+func readNetrc(path string) (map[string]netrcEntry, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]netrcEntry)
+	var machine *string
+	var entry netrcEntry
+	add := func() {
+		// The first entry of a machine wins
+		if _, found := entries[*machine]; !found {
+			entries[*machine] = entry
+		}
+	}
+
+	var inMacro bool
+	for _, line := range strings.Split(string(content), "\n") {
+		// Macro definitions end with an empty line
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		tokens := strings.Fields(line)
+		for i := 0; i < len(tokens); i++ {
+			token := tokens[i]
+			if strings.HasPrefix(token, "#") {
+				break
+			}
+			switch token {
+			case "default":
+				if machine != nil {
+					add()
+				}
+				name := ""
+				machine, entry = &name, netrcEntry{}
+				continue
+			case "macdef":
+				inMacro = true
+				i = len(tokens)
+				continue
+			case "machine", "login", "password", "account":
+			default:
+				return nil, fmt.Errorf("unexpected token %q in %q", token, path)
+			}
+
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("missing value of %q in %q", token, path)
+			}
+			i++
+			value := tokens[i]
+			switch token {
+			case "machine":
+				if machine != nil {
+					add()
+				}
+				name := strings.ToLower(value)
+				machine, entry = &name, netrcEntry{}
+			case "login":
+				entry.login = value
+			case "password":
+				entry.password = value
+			}
+		}
+	}
+	if machine != nil {
+		add()
+	}
+	return entries, nil
+}
+
+// lookupNetrc returns the complete netrc login of the given host, falling
+// back to the "default" entry
+func lookupNetrc(entries map[string]netrcEntry, host string) (netrcEntry, bool) {
+	entry, found := entries[strings.ToLower(host)]
+	if !found {
+		entry, found = entries[""]
+	}
+	return entry, found && entry.login != "" && entry.password != ""
+}
+
+// mergeHeaders returns the top-level headers overridden by the headers of a
+// single URL, header names are compared case-insensitively
+func mergeHeaders(defaults, overrides map[string]*config.Secret) map[string]*config.Secret {
+	headers := make(map[string]*config.Secret, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range overrides {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+	return headers
+}
+
+// setRequestHeaders sets the configured headers, resolving secret-store
+// references on every request so rotated values are picked up
+func setRequestHeaders(req *http.Request, headers map[string]*config.Secret) error {
+	for k, secret := range headers {
+		if secret == nil {
+			continue
+		}
+		value, err := secret.Get()
+		if err != nil {
+			return fmt.Errorf("getting header %q failed: %w", k, err)
+		}
+		if strings.ToLower(k) == "host" {
+			req.Host = string(value)
+		} else {
+			req.Header.Set(k, string(value))
+		}
+		releaseSecret(value)
+	}
+	return nil
+}
+
+// setRequestAuth attaches the credentials to the request and reports whether
+// basic auth is deferred until the server challenges the request
+func (a *Alerta) setRequestAuth(req *http.Request, auth *credentials) (bool, error) {
+	// The OAuth2 client attaches its token itself
+	if a.OAuth2ClientID != "" {
+		return false, nil
+	}
+
+	// The API key takes precedence, basic auth is only used if no key is set
+	// or the key resolves to an empty value.
+	ok, err := a.setAPIKey(req, auth.apiKey)
+	if err != nil || ok {
+		return false, err
+	}
+	if !a.PreemptiveBasicAuth {
+		return !auth.username.Empty() && !auth.password.Empty(), nil
+	}
+	return false, setBasicAuth(req, auth.username, auth.password)
+}
+
+// isBasicChallenge checks if the response asks for basic auth credentials
+func isBasicChallenge(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		scheme, _, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+		if strings.EqualFold(scheme, "basic") {
+			return true
+		}
+	}
+	return false
+}
+
+// setAPIKey attaches the API key to the request using the configured scheme
+// and reports whether a key was set
+func (a *Alerta) setAPIKey(req *http.Request, apiKey *config.Secret) (bool, error) {
+	if apiKey.Empty() {
+		return false, nil
+	}
+
+	token, err := apiKey.Get()
+	if err != nil {
+		return false, fmt.Errorf("getting api_key failed: %w", err)
+	}
+	defer releaseSecret(token)
+
+	key := string(token)
+	if a.APIKeyIsFile {
+		content, err := os.ReadFile(key)
+		if err != nil {
+			return false, fmt.Errorf("reading api_key file failed: %w", err)
+		}
+		defer memguard.WipeBytes(content)
+		key = strings.TrimSpace(string(content))
+	}
+	if key == "" {
+		return false, nil
+	}
+
+	switch a.AuthScheme {
+	case "", "bearer":
+		req.Header.Set("Authorization", "Bearer "+key)
+	case "api-key":
+		req.Header.Set("X-API-Key", key)
+	case "key":
+		req.Header.Set("Authorization", "Key "+key)
+	}
+
+	return true, nil
+}
+
+func setBasicAuth(req *http.Request, user, pass *config.Secret) error {
+	if user.Empty() || pass.Empty() {
+		return nil
+	}
+
+	username, err := user.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer releaseSecret(username)
+
+	password, err := pass.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer releaseSecret(password)
+
+	if len(username) > 0 && len(password) > 0 {
+		req.SetBasicAuth(string(username), string(password))
+	}
+
+	return nil
+}
+
+func init() {
+	inputs.Add("alerta", func() telegraf.Input {
+		return &Alerta{
+			Path:         defaultStatusPath,
+			Groups:       []string{"alerts"},
+			Measurement:  "alerta",
+			URLTag:       "url",
+			UserAgent:    defaultUserAgent,
+			RetryBackoff: config.Duration(time.Second),
+			KeepAlive:    config.Duration(30 * time.Second),
+			MaxBodySize:  config.Size(defaultMaxBodySize),
+			AuthScheme:   "bearer",
+
+			MaxConcurrentRequests: 10,
+			PreemptiveBasicAuth:   true,
+			FollowRedirects:       true,
+		}
+	})
+}